@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"transactionriskassessment/audit"
+	"transactionriskassessment/client"
+	"transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+
+	"github.com/gin-gonic/gin"
+)
+
+// header identifying a request, generated when the client does not send one
+const requestIdHeader = "X-Request-Id"
+
+// audit trail of every assessment, nil unless AUDIT_LOG is set
+var auditStore *audit.Store
+
+// returns a random request id
+func newRequestId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// identifies who sent a request without storing their API key: a hash of the key when there is one,
+// the address otherwise
+func clientIdentity(apiKey, address string) string {
+	if apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	return "address:" + address
+}
+
+// assesses the transactions with the tenant rules and, when enabled, records the assessment in the audit trail and
+// enqueues its risky transactions for review before returning it. An assessment that cannot be recorded is not returned,
+// one that cannot be enqueued is, the failure being logged.
+// Requests and ratings are counted in the tenant metrics
+func assessAndRecord(ctx context.Context, tenant *tenant, requestId, client string, transactions []domain.Transaction) (domain.RiskRateResults, error) {
+	results, err := tenant.assess(ctx, requestId, client, transactions)
+	tenant.recordMetrics(results, err)
+	return results, err
+}
+
+func (tenant *tenant) assess(ctx context.Context, requestId, client string, transactions []domain.Transaction) (domain.RiskRateResults, error) {
+	// remembered only once recorded, a duplicate or unrecorded request leaves the history as it was
+	pending, err := tenant.engine.ExplainPending(ctx, transactions)
+	if err != nil {
+		return domain.RiskRateResults{}, err
+	}
+	assessments := pending.Assessments
+
+	if auditStore != nil {
+		record := audit.Record{
+			RequestId:      requestId,
+			Client:         client,
+			Tenant:         tenant.id,
+			RuleSetVersion: tenant.engine.Version(),
+			Transactions:   make([]audit.Entry, len(assessments)),
+		}
+		for index, assessment := range assessments {
+			record.Transactions[index] = audit.Entry{
+				// as received, the assessed risk has its own field
+				Transaction:  transactions[index],
+				Risk:         assessment.Transaction.RiskRate.String(),
+				MatchedRules: assessment.MatchedRules,
+			}
+		}
+		// Append refuses request ids already recorded, under the same lock it records them with
+		if _, err := auditStore.Append(record); err != nil {
+			return domain.RiskRateResults{}, fmt.Errorf("recording assessment: %w", err)
+		}
+	}
+	pending.Commit(ctx)
+	// the assessment is already recorded, failing now would only make the retry a duplicate. Enqueueing before
+	// recording is no better, duplicates would be enqueued before being refused
+	if err := enqueueForReview(tenant.id, requestId, transactions, assessments); err != nil {
+		log.Printf("enqueueing request %s for review: %v", requestId, err)
+	}
+	results := engine.Results(assessments)
+	results.Actions = tenant.engine.Decide(assessments)
+	results.RuleSetVersion = tenant.engine.Version()
+	return results, nil
+}
+
+// GetAssessment returns the audit record of the request id in the path, when it was assessed for the tenant
+func GetAssessment(context *gin.Context) {
+	if auditStore == nil {
+		context.JSON(http.StatusNotFound, gin.H{"error": "audit trail is disabled"})
+		return
+	}
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	record, err := auditStore.Get(context.Param("request_id"))
+	if err == nil && !tenant.owns(record.Tenant) {
+		// other tenants records are not told apart from missing ones
+		err = audit.ErrNotFound
+	}
+	if errors.Is(err, audit.ErrNotFound) {
+		context.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	context.IndentedJSON(http.StatusOK, record)
+}
@@ -0,0 +1,213 @@
+// Package audit keeps an append-only trail of every assessment, so what was decided and why can be proven later.
+// Records are stored one JSON object per line, each one carrying the hash of the previous, so editing or
+// removing a record breaks the chain from that point on
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"transactionriskassessment/domain"
+)
+
+var (
+	// ErrNotFound is returned by Get for request ids with no record
+	ErrNotFound = errors.New("assessment not found")
+	// ErrDuplicateRequest is returned by Append when the request id was already recorded
+	ErrDuplicateRequest = errors.New("request id already recorded")
+)
+
+// Entry is one assessed transaction
+type Entry struct {
+	Transaction  domain.Transaction `json:"transaction"`
+	Risk         string             `json:"risk"`
+	MatchedRules []string           `json:"matched_rules"`
+}
+
+// Record is one assessment, as stored
+type Record struct {
+	// position in the trail, starting at 1
+	Sequence  uint64 `json:"sequence"`
+	RequestId string `json:"request_id"`
+	Client    string `json:"client"`
+	// business unit whose rules assessed the transactions, empty in records written before tenants existed
+	Tenant         string    `json:"tenant,omitempty"`
+	Time           time.Time `json:"time"`
+	RuleSetVersion string    `json:"rule_set_version"`
+	Transactions   []Entry   `json:"transactions"`
+	// hash of the previous record, empty for the first one
+	PreviousHash string `json:"previous_hash"`
+	// SHA-256 of the record encoded with an empty Hash
+	Hash string `json:"hash"`
+}
+
+// computes the hash of the record, ignoring its current Hash
+func (record Record) computeHash() (string, error) {
+	record.Hash = ""
+	content, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// where a record is in the file
+type span struct {
+	offset int64
+	length int
+}
+
+// Store is an audit trail kept in a local file. It is safe for concurrent use
+type Store struct {
+	mutex        sync.Mutex
+	file         *os.File
+	size         int64
+	lastSequence uint64
+	lastHash     string
+	requests     map[string]span
+}
+
+// Open opens the trail at path, creating it when missing. Existing records are verified first
+func Open(path string) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{file: file, requests: make(map[string]span)}
+	if err := store.scan(func(record Record, recordSpan span) { store.requests[record.RequestId] = recordSpan }); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit trail %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// reads every record from the start of the file, checking the chain, and leaves the store positioned after the last one
+func (store *Store) scan(visit func(Record, span)) error {
+	reader := bufio.NewReader(io.NewSectionReader(store.file, 0, 1<<62))
+	var offset int64
+	var lastSequence uint64
+	var lastHash string
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err == io.EOF {
+			// a write interrupted midway leaves a line without its newline
+			return fmt.Errorf("record after sequence %d is incomplete", lastSequence)
+		}
+		if err != nil {
+			return err
+		}
+
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("record after sequence %d: %w", lastSequence, err)
+		}
+		hash, err := record.computeHash()
+		if err != nil {
+			return err
+		}
+		if record.Sequence != lastSequence+1 || record.PreviousHash != lastHash || record.Hash != hash {
+			return fmt.Errorf("chain broken at sequence %d", lastSequence+1)
+		}
+
+		visit(record, span{offset: offset, length: len(line)})
+		offset += int64(len(line))
+		lastSequence, lastHash = record.Sequence, record.Hash
+	}
+
+	store.size, store.lastSequence, store.lastHash = offset, lastSequence, lastHash
+	return nil
+}
+
+// Verify reads the whole trail again, returning an error naming the first record that was altered
+func (store *Store) Verify() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.scan(func(Record, span) {})
+}
+
+// Append chains the record after the last one and writes it. Sequence, PreviousHash and Hash are set by
+// the store, Time too when zero. It returns the stored record
+func (store *Store) Append(record Record) (Record, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, isRecorded := store.requests[record.RequestId]; isRecorded {
+		return record, ErrDuplicateRequest
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	record.Sequence = store.lastSequence + 1
+	record.PreviousHash = store.lastHash
+	hash, err := record.computeHash()
+	if err != nil {
+		return record, err
+	}
+	record.Hash = hash
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return record, err
+	}
+	line = append(line, '\n')
+	// the chain only moves forward once the whole line is written
+	if _, err := store.file.WriteAt(line, store.size); err != nil {
+		return record, err
+	}
+	if err := store.file.Sync(); err != nil {
+		return record, err
+	}
+
+	store.requests[record.RequestId] = span{offset: store.size, length: len(line)}
+	store.size += int64(len(line))
+	store.lastSequence, store.lastHash = record.Sequence, record.Hash
+	return record, nil
+}
+
+// Has tells whether the request id was already recorded
+func (store *Store) Has(requestId string) bool {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	_, isRecorded := store.requests[requestId]
+	return isRecorded
+}
+
+// Get returns the record of the request id, or ErrNotFound
+func (store *Store) Get(requestId string) (Record, error) {
+	store.mutex.Lock()
+	recordSpan, isRecorded := store.requests[requestId]
+	store.mutex.Unlock()
+	if !isRecorded {
+		return Record{}, ErrNotFound
+	}
+
+	line := make([]byte, recordSpan.length)
+	if _, err := store.file.ReadAt(line, recordSpan.offset); err != nil {
+		return Record{}, err
+	}
+	var record Record
+	if err := json.Unmarshal(line, &record); err != nil {
+		return Record{}, err
+	}
+	return record, nil
+}
+
+// Close closes the file, the store cannot be used afterwards
+func (store *Store) Close() error {
+	return store.file.Close()
+}
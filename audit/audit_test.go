@@ -0,0 +1,141 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// opens a store in a temporary directory, closed when the test ends
+func openTempStore(t *testing.T) (*Store, string) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, path
+}
+
+func testRecord(requestId string) Record {
+	return Record{
+		RequestId:      requestId,
+		Client:         "address:192.0.2.1",
+		RuleSetVersion: "sha256:000000000000",
+		Transactions: []Entry{{
+			Transaction:  domain.Transaction{TransactionId: 1, UserId: 1, Amount: domain.USD(1100000), IdCardUsed: 1},
+			Risk:         "high",
+			MatchedRules: []string{"single_amount", "total_amount"},
+		}},
+	}
+}
+
+func TestStoreAppendAndGet(t *testing.T) {
+	store, _ := openTempStore(t)
+
+	first, err := store.Append(testRecord("first"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), first.Sequence)
+	assert.Empty(t, first.PreviousHash)
+	assert.Len(t, first.Hash, 64)
+	assert.False(t, first.Time.IsZero())
+
+	second, err := store.Append(testRecord("second"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), second.Sequence)
+	assert.Equal(t, first.Hash, second.PreviousHash)
+
+	got, err := store.Get("first")
+	assert.NoError(t, err)
+	assert.Equal(t, first, got)
+	assert.True(t, store.Has("second"))
+
+	_, err = store.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Append(testRecord("first"))
+	assert.ErrorIs(t, err, ErrDuplicateRequest)
+	assert.NoError(t, store.Verify())
+}
+
+func TestOpen_ContinuesChain(t *testing.T) {
+	store, path := openTempStore(t)
+	first, err := store.Append(testRecord("first"))
+	assert.NoError(t, err)
+	store.Close()
+
+	reopened, err := Open(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+
+	got, err := reopened.Get("first")
+	assert.NoError(t, err)
+	assert.Equal(t, first, got)
+
+	second, err := reopened.Append(testRecord("second"))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), second.Sequence)
+	assert.Equal(t, first.Hash, second.PreviousHash)
+	_, err = reopened.Append(testRecord("first"))
+	assert.ErrorIs(t, err, ErrDuplicateRequest)
+}
+
+func TestOpen_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(content string) string
+		want   string
+	}{
+		{
+			name: "edited risk",
+			tamper: func(content string) string {
+				return strings.Replace(content, `"risk":"high"`, `"risk":"low"`, 1)
+			},
+			want: "chain broken at sequence 1",
+		},
+		{
+			name: "removed record",
+			tamper: func(content string) string {
+				lines := strings.SplitAfter(content, "\n")
+				return strings.Join(append(lines[:1], lines[2:]...), "")
+			},
+			want: "chain broken at sequence 2",
+		},
+		{
+			name:   "incomplete last record",
+			tamper: func(content string) string { return content[:len(content)-10] },
+			want:   "record after sequence 2 is incomplete",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, path := openTempStore(t)
+			for _, requestId := range []string{"first", "second", "third"} {
+				_, err := store.Append(testRecord(requestId))
+				assert.NoError(t, err)
+			}
+			store.Close()
+
+			content, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.NoError(t, os.WriteFile(path, []byte(test.tamper(string(content))), 0o600))
+
+			_, err = Open(path)
+			assert.ErrorContains(t, err, test.want)
+		})
+	}
+}
+
+func TestStoreAppend_KeepsGivenTime(t *testing.T) {
+	store, _ := openTempStore(t)
+	record := testRecord("timed")
+	record.Time = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	got, err := store.Append(record)
+	assert.NoError(t, err)
+	assert.Equal(t, record.Time, got.Time)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"transactionriskassessment/audit"
+	"transactionriskassessment/riskpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// enables the audit trail, in a temporary file, for the duration of the test
+func enableTestAudit(t *testing.T) {
+	store, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auditStore = store
+	t.Cleanup(func() {
+		auditStore = nil
+		store.Close()
+	})
+}
+
+// sends the request to the router, returning the recorded response
+func serveTestRequest(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	setupRouter().ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAssessTransactions_Audit(t *testing.T) {
+	enableTestAudit(t)
+	document := loadOpenAPIDocument(t)
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 1100000, "card_id": 1}, {"id": 2, "user_id": 2, "amount_us_cents": 1, "card_id": 2}]}`
+
+	recorder := serveTestRequest("POST", "/check_transactions", payload, map[string]string{requestIdHeader: "request-1", "X-API-Key": "secret"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "request-1", recorder.Header().Get(requestIdHeader))
+
+	// the same request id cannot be assessed twice
+	recorder = serveTestRequest("POST", "/check_transactions", payload, map[string]string{requestIdHeader: "request-1"})
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = serveTestRequest("GET", "/assessments/request-1", "", nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var body any
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+	assert.Empty(t, validateSchema(document, responseSchema(t, document, "GET", "/assessments/{request_id}", http.StatusOK), body, "response"))
+
+	var record audit.Record
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &record))
+	assert.Equal(t, "request-1", record.RequestId)
+	assert.Equal(t, clientIdentity("secret", ""), record.Client)
+	assert.NotContains(t, recorder.Body.String(), "secret")
+	assert.Equal(t, riskEngine.Config().Version(), record.RuleSetVersion)
+	assert.Equal(t, "high", record.Transactions[0].Risk)
+	assert.Equal(t, []string{"single_amount", "total_amount"}, record.Transactions[0].MatchedRules)
+	assert.Equal(t, "low", record.Transactions[1].Risk)
+	assert.Nil(t, record.Transactions[1].MatchedRules)
+}
+
+func TestAssessTransactions_ConcurrentDuplicates(t *testing.T) {
+	useTestFeedback(t)
+	enableTestAudit(t)
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 2000, "card_id": 1}]}`
+
+	const requests = 8
+	statuses := make(chan int, requests)
+	var wait sync.WaitGroup
+	for index := 0; index < requests; index++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			statuses <- serveTestRequest("POST", "/check_transactions", payload, map[string]string{requestIdHeader: "request-1"}).Code
+		}()
+	}
+	wait.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, map[int]int{http.StatusOK: 1, http.StatusConflict: requests - 1}, counts)
+	// only the recorded assessment is remembered
+	assert.Len(t, riskEngine.History().UserAmounts(1), 1)
+}
+
+func TestAssessTransactions_GeneratesRequestId(t *testing.T) {
+	enableTestAudit(t)
+
+	recorder := serveTestRequest("POST", "/check_transactions", `{"transactions": []}`, nil)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	requestId := recorder.Header().Get(requestIdHeader)
+	assert.Len(t, requestId, 32)
+	assert.True(t, auditStore.Has(requestId))
+}
+
+func TestGetAssessment_NotFound(t *testing.T) {
+	document := loadOpenAPIDocument(t)
+
+	// disabled, then enabled without the request
+	for _, enable := range []bool{false, true} {
+		if enable {
+			enableTestAudit(t)
+		}
+		recorder := serveTestRequest("GET", "/assessments/missing", "", nil)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		var body any
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+		assert.Empty(t, validateSchema(document, responseSchema(t, document, "GET", "/assessments/{request_id}", http.StatusNotFound), body, "response"))
+	}
+}
+
+func TestGetAssessment_Tenants(t *testing.T) {
+	enableTestAudit(t)
+	enableTestTenants(t)
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 2000, "card_id": 1}]}`
+	serveTestRequest("POST", "/check_transactions", payload, map[string]string{requestIdHeader: "cards-1", tenantHeader: "cards"})
+	serveTestRequest("POST", "/check_transactions", payload, map[string]string{requestIdHeader: "default-1"})
+
+	tests := []struct {
+		name      string
+		requestId string
+		headers   map[string]string
+		status    int
+	}{
+		{name: "own record", requestId: "cards-1", headers: map[string]string{tenantHeader: "cards"}, status: http.StatusOK},
+		{name: "own record by API key", requestId: "cards-1", headers: map[string]string{"X-API-Key": "cards-key"}, status: http.StatusOK},
+		{name: "default tenant record", requestId: "default-1", status: http.StatusOK},
+		{name: "record of another tenant", requestId: "cards-1", headers: map[string]string{tenantHeader: "loans"}, status: http.StatusNotFound},
+		{name: "record of the default tenant", requestId: "default-1", headers: map[string]string{tenantHeader: "cards"}, status: http.StatusNotFound},
+		{name: "record of a tenant without tenant header", requestId: "cards-1", status: http.StatusNotFound},
+		{name: "unknown tenant", requestId: "cards-1", headers: map[string]string{tenantHeader: "unknown"}, status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveTestRequest("GET", "/assessments/"+test.requestId, "", test.headers)
+			assert.Equal(t, test.status, recorder.Code)
+		})
+	}
+}
+
+func TestGRPCCheckTransactions_Audit(t *testing.T) {
+	enableTestAudit(t)
+	client := newTestGRPCClient(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), requestIdMetadata, "grpc-1")
+
+	var header metadata.MD
+	_, err := client.CheckTransactions(ctx, grpcInputMock, grpc.Header(&header))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"grpc-1"}, header.Get(requestIdMetadata))
+	record, err := auditStore.Get("grpc-1")
+	assert.NoError(t, err)
+	assert.Len(t, record.Transactions, len(grpcInputMock.Transactions))
+
+	_, err = client.CheckTransactions(ctx, grpcInputMock)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestGRPCStreamTransactions_Audit(t *testing.T) {
+	enableTestAudit(t)
+	client := newTestGRPCClient(t)
+
+	stream, err := client.StreamTransactions(metadata.AppendToOutgoingContext(context.Background(), requestIdMetadata, "stream"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range []int{1, 2} {
+		assert.NoError(t, stream.Send(&riskpb.TransactionsInput{Transactions: []*riskpb.Transaction{{Id: 1, UserId: 1, CardId: 1}}}))
+		_, err := stream.Recv()
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, stream.CloseSend())
+
+	// one record per batch
+	assert.True(t, auditStore.Has("stream-1"))
+	assert.True(t, auditStore.Has("stream-2"))
+}
@@ -0,0 +1,270 @@
+// Package backtest replays transactions labelled as fraud or not through the risk engine, measuring how well
+// a rule configuration would have performed on them
+package backtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	. "transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+)
+
+// LabelledTransaction is a past transaction and whether it turned out to be fraud
+type LabelledTransaction struct {
+	Transaction
+	Fraud bool `json:"fraud"`
+}
+
+// LabelledInput is the format of labelled files, the API input with a fraud flag on each transaction
+type LabelledInput struct {
+	Transactions []LabelledTransaction `json:"transactions"`
+}
+
+// LoadLabelled reads a labelled JSON file
+func LoadLabelled(path string) ([]LabelledTransaction, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var input LabelledInput
+	if err := json.Unmarshal(content, &input); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return input.Transactions, nil
+}
+
+// ThresholdResult measures the rules when transactions at or above Risk are considered flagged as fraud
+type ThresholdResult struct {
+	Risk           RiskLevel
+	TruePositives  int
+	FalsePositives int
+	FalseNegatives int
+	TrueNegatives  int
+}
+
+// Precision is the share of flagged transactions that were fraud, 0 when nothing was flagged
+func (result ThresholdResult) Precision() float64 {
+	return ratio(result.TruePositives, result.TruePositives+result.FalsePositives)
+}
+
+// Recall is the share of fraud that was flagged, 0 when there was no fraud
+func (result ThresholdResult) Recall() float64 {
+	return ratio(result.TruePositives, result.TruePositives+result.FalseNegatives)
+}
+
+// RuleContribution tells how much a single rule helped
+type RuleContribution struct {
+	Rule string
+	// transactions the rule rated above low risk, and how many of them were fraud
+	Matched      int
+	MatchedFraud int
+	// transactions no other rule rated above low risk, and how many of them were fraud
+	OnlyRule      int
+	OnlyRuleFraud int
+}
+
+// Precision is the share of the transactions matched by the rule that were fraud
+func (contribution RuleContribution) Precision() float64 {
+	return ratio(contribution.MatchedFraud, contribution.Matched)
+}
+
+// Report is the outcome of replaying a labelled file
+type Report struct {
+	Transactions int
+	Fraud        int
+	// transactions by label, fraud first, and assessed risk
+	Confusion [2][3]int
+	// flagging from medium and from high risk
+	Thresholds []ThresholdResult
+	// every rule of the configuration, in evaluation order
+	Rules []RuleContribution
+}
+
+// Run assesses the transactions with the configuration, batchSize at a time in file order, with a history so
+// later batches see earlier ones as the server would. A batchSize of 0 assesses the whole file at once
+func Run(ctx context.Context, config engine.Config, transactions []LabelledTransaction, batchSize int) (Report, error) {
+	riskEngine := engine.New(engine.WithConfig(config), engine.WithHistory(engine.NewHistory()))
+	if batchSize <= 0 {
+		batchSize = len(transactions)
+	}
+
+	report := Report{Transactions: len(transactions)}
+	for _, rule := range riskEngine.Config().Rules() {
+		report.Rules = append(report.Rules, RuleContribution{Rule: rule.Name})
+	}
+	ruleIndexes := make(map[string]int, len(report.Rules))
+	for index, contribution := range report.Rules {
+		ruleIndexes[contribution.Rule] = index
+	}
+
+	for start := 0; start < len(transactions); start += batchSize {
+		end := min(start+batchSize, len(transactions))
+		batch := make([]Transaction, 0, end-start)
+		for _, labelled := range transactions[start:end] {
+			batch = append(batch, labelled.Transaction)
+		}
+
+		assessments, err := riskEngine.Explain(ctx, batch)
+		if err != nil {
+			return report, err
+		}
+		for index, assessment := range assessments {
+			report.add(assessment, transactions[start+index].Fraud, ruleIndexes)
+		}
+	}
+
+	for _, risk := range []RiskLevel{MEDIUM, HIGH} {
+		report.Thresholds = append(report.Thresholds, report.threshold(risk))
+	}
+	return report, nil
+}
+
+// counts one assessed transaction
+func (report *Report) add(assessment engine.Assessment, fraud bool, ruleIndexes map[string]int) {
+	label := 1
+	if fraud {
+		report.Fraud++
+		label = 0
+	}
+	report.Confusion[label][assessment.Transaction.RiskRate]++
+
+	for _, ruleName := range assessment.MatchedRules {
+		contribution := &report.Rules[ruleIndexes[ruleName]]
+		contribution.Matched++
+		if fraud {
+			contribution.MatchedFraud++
+		}
+		if len(assessment.MatchedRules) == 1 {
+			contribution.OnlyRule++
+			if fraud {
+				contribution.OnlyRuleFraud++
+			}
+		}
+	}
+}
+
+// reads the confusion matrix as if transactions at or above risk were flagged
+func (report *Report) threshold(risk RiskLevel) ThresholdResult {
+	result := ThresholdResult{Risk: risk}
+	for level := LOW; level <= HIGH; level++ {
+		if level >= risk {
+			result.TruePositives += report.Confusion[0][level]
+			result.FalsePositives += report.Confusion[1][level]
+		} else {
+			result.FalseNegatives += report.Confusion[0][level]
+			result.TrueNegatives += report.Confusion[1][level]
+		}
+	}
+	return result
+}
+
+// SweepResult is the report obtained with one value of the swept setting
+type SweepResult struct {
+	Value  string
+	Report Report
+}
+
+// Sweep runs the backtest once per value of a setting, given by its JSON path in the configuration file,
+// e.g. "merchant.new_merchant_amount_us_cents". Values are JSON, as they would be written in the file
+func Sweep(ctx context.Context, config engine.Config, setting string, values []string, transactions []LabelledTransaction, batchSize int) ([]SweepResult, error) {
+	var results []SweepResult
+	for _, value := range values {
+		sweptConfig, err := withSetting(config, setting, value)
+		if err != nil {
+			return nil, err
+		}
+		report, err := Run(ctx, sweptConfig, transactions, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SweepResult{Value: value, Report: report})
+	}
+	return results, nil
+}
+
+// ParseSweep reads a -sweep flag, the setting and a JSON array of its values, e.g.
+// merchant.new_merchant_amount_us_cents=[100000,300000] or merchant.high_risk_categories=[["7995"],["7995","6051"]]
+func ParseSweep(sweep string) (string, []string, error) {
+	setting, list, ok := strings.Cut(sweep, "=")
+	if !ok || setting == "" {
+		return "", nil, fmt.Errorf("sweep %s is not setting=[value1,value2,...]", sweep)
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal([]byte(list), &values); err != nil {
+		return "", nil, fmt.Errorf("sweep values %s are not a JSON array: %w", list, err)
+	}
+	if len(values) == 0 {
+		return "", nil, fmt.Errorf("sweep values of %s are empty", setting)
+	}
+	texts := make([]string, len(values))
+	for index, value := range values {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, value); err != nil {
+			return "", nil, err
+		}
+		texts[index] = compacted.String()
+	}
+	return setting, texts, nil
+}
+
+// returns a copy of config with the setting at the dotted JSON path replaced by value
+func withSetting(config engine.Config, setting, value string) (engine.Config, error) {
+	content, err := json.Marshal(config)
+	if err != nil {
+		return config, err
+	}
+	var document map[string]any
+	if err := json.Unmarshal(content, &document); err != nil {
+		return config, err
+	}
+
+	var parsedValue any
+	if err := json.Unmarshal([]byte(value), &parsedValue); err != nil {
+		return config, fmt.Errorf("sweep value %s is not JSON: %w", value, err)
+	}
+
+	keys := strings.Split(setting, ".")
+	object := document
+	for _, key := range keys[:len(keys)-1] {
+		child, isObject := object[key].(map[string]any)
+		if !isObject {
+			return config, fmt.Errorf("unknown setting %s", setting)
+		}
+		object = child
+	}
+	if _, isKnownSetting := object[keys[len(keys)-1]]; !isKnownSetting {
+		return config, fmt.Errorf("unknown setting %s", setting)
+	}
+	object[keys[len(keys)-1]] = parsedValue
+
+	if content, err = json.Marshal(document); err != nil {
+		return config, err
+	}
+	var sweptConfig engine.Config
+	if err := json.Unmarshal(content, &sweptConfig); err != nil {
+		return config, fmt.Errorf("setting %s to %s: %w", setting, value, err)
+	}
+	// the model is not part of the JSON document
+	sweptConfig.Model.Model = config.Model.Model
+	if err := sweptConfig.Validate(); err != nil {
+		return config, fmt.Errorf("setting %s to %s: %w", setting, value, err)
+	}
+	return sweptConfig, nil
+}
+
+func ratio(numerator, denominator int) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return float64(numerator) / float64(denominator)
+}
+
+// formats a ratio as a percentage with one decimal
+func percent(value float64) string {
+	return strconv.FormatFloat(value*100, 'f', 1, 64) + "%"
+}
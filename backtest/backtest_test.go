@@ -0,0 +1,133 @@
+package backtest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	. "transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestdata(t *testing.T) []LabelledTransaction {
+	transactions, err := LoadLabelled(filepath.Join("testdata", "labelled.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transactions
+}
+
+// the default configuration with the new merchant and card sharing rules enabled
+func testConfig() engine.Config {
+	config := engine.DefaultConfig()
+	config.Merchant.NewMerchantAmount = 300000
+	config.CardSharing = engine.UsersThresholds{MediumRiskUsers: 1, HighRiskUsers: 2}
+	return config
+}
+
+func TestLoadLabelled(t *testing.T) {
+	transactions := loadTestdata(t)
+	assert.Len(t, transactions, 7)
+	assert.Equal(t, LabelledTransaction{
+		Transaction: Transaction{TransactionId: 3, UserId: 1, Amount: USD(1100000), IdCardUsed: 1},
+		Fraud:       true,
+	}, transactions[2])
+
+	_, err := LoadLabelled(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRun(t *testing.T) {
+	report, err := Run(context.Background(), testConfig(), loadTestdata(t), 0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 7, report.Transactions)
+	assert.Equal(t, 4, report.Fraud)
+	assert.Equal(t, [2][3]int{{0, 2, 2}, {2, 1, 0}}, report.Confusion)
+	assert.Equal(t, []ThresholdResult{
+		{Risk: MEDIUM, TruePositives: 4, FalsePositives: 1, TrueNegatives: 2},
+		{Risk: HIGH, TruePositives: 2, FalseNegatives: 2, TrueNegatives: 3},
+	}, report.Thresholds)
+	assert.Equal(t, 0.8, report.Thresholds[0].Precision())
+	assert.Equal(t, 0.5, report.Thresholds[1].Recall())
+
+	contributions := make(map[string]RuleContribution)
+	for _, contribution := range report.Rules {
+		contributions[contribution.Rule] = contribution
+	}
+	assert.Equal(t, RuleContribution{Rule: "single_amount", Matched: 2, MatchedFraud: 1, OnlyRule: 1}, contributions["single_amount"])
+	assert.Equal(t, RuleContribution{Rule: "multiple_cards", Matched: 2, MatchedFraud: 2, OnlyRule: 2, OnlyRuleFraud: 2}, contributions["multiple_cards"])
+	assert.Equal(t, 0.5, contributions["single_amount"].Precision())
+}
+
+func TestRun_Batches(t *testing.T) {
+	// one transaction at a time, the history still sees the card used by two users
+	transactions := []LabelledTransaction{
+		{Transaction: Transaction{TransactionId: 1, UserId: 1, IdCardUsed: 7}},
+		{Transaction: Transaction{TransactionId: 2, UserId: 2, IdCardUsed: 7}, Fraud: true},
+	}
+	report, err := Run(context.Background(), testConfig(), transactions, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, [2][3]int{{0, 1, 0}, {1, 0, 0}}, report.Confusion)
+}
+
+func TestSweep(t *testing.T) {
+	results, err := Sweep(context.Background(), testConfig(), "merchant.new_merchant_amount_us_cents", []string{"0", "300000"}, loadTestdata(t), 0)
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "0", results[0].Value)
+	// the new merchant rule catches the fraud of user 3 only when enabled
+	assert.Equal(t, 3, results[0].Report.Thresholds[0].TruePositives)
+	assert.Equal(t, 4, results[1].Report.Thresholds[0].TruePositives)
+}
+
+func TestParseSweep(t *testing.T) {
+	tests := []struct {
+		name        string
+		sweep       string
+		wantSetting string
+		wantValues  []string
+		wantErr     bool
+	}{
+		{name: "numbers", sweep: "merchant.new_merchant_amount_us_cents=[100000, 300000]", wantSetting: "merchant.new_merchant_amount_us_cents", wantValues: []string{"100000", "300000"}},
+		{name: "arrays and objects should be kept whole", sweep: `merchant.high_risk_categories=[["7995"], ["7995","6051"], {"a": 1}]`, wantSetting: "merchant.high_risk_categories", wantValues: []string{`["7995"]`, `["7995","6051"]`, `{"a":1}`}},
+		{name: "no values", sweep: "merchant.new_merchant_amount_us_cents", wantErr: true},
+		{name: "no setting", sweep: "=[1]", wantErr: true},
+		{name: "comma separated values", sweep: "merchant.new_merchant_amount_us_cents=1,2", wantErr: true},
+		{name: "empty array", sweep: "merchant.new_merchant_amount_us_cents=[]", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setting, values, err := ParseSweep(test.sweep)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantSetting, setting)
+			assert.Equal(t, test.wantValues, values)
+		})
+	}
+}
+
+func TestWithSetting_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		value   string
+	}{
+		{name: "unknown section", setting: "unknown.amount", value: "1"},
+		{name: "unknown setting", setting: "merchant.unknown", value: "1"},
+		{name: "value not JSON", setting: "merchant.new_merchant_amount_us_cents", value: "abc"},
+		{name: "value of the wrong type", setting: "merchant.new_merchant_amount_us_cents", value: `"abc"`},
+		{name: "value failing validation", setting: "merchant.new_merchant_amount_us_cents", value: "-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := withSetting(testConfig(), test.setting, test.value)
+			assert.Error(t, err)
+		})
+	}
+}
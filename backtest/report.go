@@ -0,0 +1,50 @@
+package backtest
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	. "transactionriskassessment/domain"
+)
+
+// WriteReport prints the report as aligned text tables
+func WriteReport(writer io.Writer, report Report) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(table, "transactions\t%d\n", report.Transactions)
+	fmt.Fprintf(table, "fraud\t%d\n", report.Fraud)
+
+	fmt.Fprintln(table, "\nconfusion matrix")
+	fmt.Fprintln(table, "label\tlow\tmedium\thigh")
+	for label, name := range []string{"fraud", "legitimate"} {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\n", name, report.Confusion[label][LOW], report.Confusion[label][MEDIUM], report.Confusion[label][HIGH])
+	}
+
+	fmt.Fprintln(table, "\nflagged from\ttrue pos.\tfalse pos.\tfalse neg.\ttrue neg.\tprecision\trecall")
+	for _, result := range report.Thresholds {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%s\t%s\n", result.Risk, result.TruePositives, result.FalsePositives,
+			result.FalseNegatives, result.TrueNegatives, percent(result.Precision()), percent(result.Recall()))
+	}
+
+	fmt.Fprintln(table, "\nrule\tmatched\tfraud\tprecision\tonly rule\tonly rule fraud")
+	for _, contribution := range report.Rules {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%s\t%d\t%d\n", contribution.Rule, contribution.Matched, contribution.MatchedFraud,
+			percent(contribution.Precision()), contribution.OnlyRule, contribution.OnlyRuleFraud)
+	}
+	return table.Flush()
+}
+
+// WriteSweep prints one line per swept value with the precision and recall of each threshold
+func WriteSweep(writer io.Writer, setting string, results []SweepResult) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(table, "%s\tmedium precision\tmedium recall\thigh precision\thigh recall\n", setting)
+	for _, result := range results {
+		fmt.Fprintf(table, "%s", result.Value)
+		for _, threshold := range result.Report.Thresholds {
+			fmt.Fprintf(table, "\t%s\t%s", percent(threshold.Precision()), percent(threshold.Recall()))
+		}
+		fmt.Fprintln(table)
+	}
+	return table.Flush()
+}
@@ -0,0 +1,30 @@
+package backtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteReport(t *testing.T) {
+	report, err := Run(context.Background(), testConfig(), loadTestdata(t), 0)
+	assert.NoError(t, err)
+
+	var output strings.Builder
+	assert.NoError(t, WriteReport(&output, report))
+	assert.Contains(t, output.String(), "fraud       0    2       2\n")
+	assert.Contains(t, output.String(), "medium        4          1           0           2          80.0%      100.0%\n")
+	assert.Contains(t, output.String(), "multiple_cards       2        2      100.0%     2          2\n")
+}
+
+func TestWriteSweep(t *testing.T) {
+	results, err := Sweep(context.Background(), testConfig(), "anomaly.window", []string{"50"}, loadTestdata(t), 0)
+	assert.NoError(t, err)
+
+	var output strings.Builder
+	assert.NoError(t, WriteSweep(&output, "anomaly.window", results))
+	assert.Equal(t, "anomaly.window  medium precision  medium recall  high precision  high recall\n"+
+		"50              80.0%             100.0%         100.0%          50.0%\n", output.String())
+}
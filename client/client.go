@@ -0,0 +1,252 @@
+// Package client calls the risk assessment HTTP API, hiding the JSON shapes defined in domain
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"transactionriskassessment/domain"
+)
+
+// default client settings, changed through options
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultRetries      = 2
+	defaultRetryBackoff = 200 * time.Millisecond
+)
+
+// header carrying the API key, when one is set
+const APIKeyHeader = "X-API-Key"
+
+// header carrying the key shared by every attempt of a call, so the API assesses a retried batch only once
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// errors matched with errors.Is against the *APIError returned by AssessTransactions
+var (
+	// 400, the request body was rejected
+	ErrBadRequest = errors.New("bad request")
+	// 422, the body was valid JSON but the transactions could not be assessed
+	ErrUnprocessable = errors.New("unprocessable transactions")
+	// 429, too many requests were sent, RetryAfter tells when to try again
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// APIError is returned for every non 200 response
+type APIError struct {
+	StatusCode int
+	// error message sent by the API, if any
+	Message string
+	// only filled for 429 responses carrying a Retry-After header
+	RetryAfter time.Duration
+}
+
+func (apiError *APIError) Error() string {
+	if apiError.Message == "" {
+		return fmt.Sprintf("risk assessment API returned %d", apiError.StatusCode)
+	}
+	return fmt.Sprintf("risk assessment API returned %d: %s", apiError.StatusCode, apiError.Message)
+}
+
+// Unwrap relates the status code to the typed errors
+func (apiError *APIError) Unwrap() error {
+	switch apiError.StatusCode {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnprocessableEntity:
+		return ErrUnprocessable
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Result pairs a transaction sent to the API with the risk assessed for it
+type Result struct {
+	Transaction domain.Transaction
+	Risk        domain.RiskLevel
+}
+
+// Client sends transactions to the risk assessment API
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	apiKey       string
+	retries      int
+	retryBackoff time.Duration
+}
+
+// Option changes a client setting
+type Option func(*Client)
+
+// WithTimeout sets the timeout of each HTTP attempt. The HTTP client given WithHTTPClient is copied first,
+// so clients shared with other code, such as http.DefaultClient, keep their own timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(client *Client) {
+		httpClient := *client.httpClient
+		httpClient.Timeout = timeout
+		client.httpClient = &httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the wait before the first retry,
+// doubled on each following one
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(client *Client) {
+		client.retries = retries
+		client.retryBackoff = backoff
+	}
+}
+
+// WithAPIKey sends the key in the X-API-Key header of every request
+func WithAPIKey(apiKey string) Option {
+	return func(client *Client) {
+		client.apiKey = apiKey
+	}
+}
+
+// WithHTTPClient replaces the underlying HTTP client, its Timeout is kept as is
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// New creates a client for the API served at baseURL, e.g. http://localhost:9090
+func New(baseURL string, options ...Option) *Client {
+	client := &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		httpClient:   &http.Client{Timeout: defaultTimeout},
+		retries:      defaultRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+// AssessTransactions sends the transactions to POST /check_transactions, returning one result per
+// transaction in the same order. Rate limited, server side and network failures are retried, with the same
+// Idempotency-Key, so a batch the API assessed before the failure is not assessed again
+func (client *Client) AssessTransactions(ctx context.Context, transactions []domain.Transaction) ([]Result, error) {
+	body, err := json.Marshal(domain.TransactionsInput{InputTransactions: transactions})
+	if err != nil {
+		return nil, err
+	}
+	idempotencyKey, err := newIdempotencyKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var ratings domain.RiskRateResults
+	backoff := client.retryBackoff
+	for attempt := 0; ; attempt++ {
+		ratings, err = client.post(ctx, "/check_transactions", body, idempotencyKey)
+		if err == nil || attempt >= client.retries || !retryable(err) {
+			break
+		}
+
+		wait := backoff
+		var apiError *APIError
+		if errors.As(err, &apiError) && apiError.RetryAfter > 0 {
+			wait = apiError.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ratings.RiskRates) != len(transactions) {
+		return nil, fmt.Errorf("risk assessment API returned %d ratings for %d transactions", len(ratings.RiskRates), len(transactions))
+	}
+	results := make([]Result, len(transactions))
+	for index, rating := range ratings.RiskRates {
+		risk, err := domain.ParseRiskLevel(rating)
+		if err != nil {
+			return nil, err
+		}
+		results[index] = Result{Transaction: transactions[index], Risk: risk}
+	}
+	return results, nil
+}
+
+// returns a random key for the attempts of one call
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generating idempotency key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// sends a single attempt, decoding the ratings or the API error
+func (client *Client) post(ctx context.Context, path string, body []byte, idempotencyKey string) (domain.RiskRateResults, error) {
+	var ratings domain.RiskRateResults
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, client.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return ratings, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(IdempotencyKeyHeader, idempotencyKey)
+	if client.apiKey != "" {
+		request.Header.Set(APIKeyHeader, client.apiKey)
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return ratings, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return ratings, newAPIError(response)
+	}
+	err = json.NewDecoder(response.Body).Decode(&ratings)
+	return ratings, err
+}
+
+// reads the {"error": "..."} body sent by the API along with the Retry-After header
+func newAPIError(response *http.Response) *APIError {
+	apiError := &APIError{StatusCode: response.StatusCode}
+
+	var errorBody struct {
+		Error string `json:"error"`
+	}
+	content, _ := io.ReadAll(response.Body)
+	if json.Unmarshal(content, &errorBody) == nil && errorBody.Error != "" {
+		apiError.Message = errorBody.Error
+	} else {
+		apiError.Message = strings.TrimSpace(string(content))
+	}
+
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiError.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiError
+}
+
+// only rate limits, server errors and transport failures are worth sending again
+func retryable(err error) bool {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError.StatusCode == http.StatusTooManyRequests || apiError.StatusCode >= http.StatusInternalServerError
+	}
+	// the caller gave up, no point in trying again
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
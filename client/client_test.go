@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/** Mock for test cases*/
+var transactionsMock = []domain.Transaction{
+	{TransactionId: 1, UserId: 1, Amount: domain.USD(200000), IdCardUsed: 1},
+	{TransactionId: 2, UserId: 1, Amount: domain.USD(600000), IdCardUsed: 1},
+}
+
+func TestAssessTransactions_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     error
+		attempts int32
+	}{
+		{
+			name:     "400 should return ErrBadRequest without retrying",
+			status:   http.StatusBadRequest,
+			body:     `{"error": "invalid character"}`,
+			want:     ErrBadRequest,
+			attempts: 1,
+		},
+		{
+			name:     "422 should return ErrUnprocessable without retrying",
+			status:   http.StatusUnprocessableEntity,
+			body:     `{"error": "negative amount"}`,
+			want:     ErrUnprocessable,
+			attempts: 1,
+		},
+		{
+			name:     "429 should return ErrRateLimited after retrying",
+			status:   http.StatusTooManyRequests,
+			body:     `{"error": "slow down"}`,
+			want:     ErrRateLimited,
+			attempts: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				attempts.Add(1)
+				writer.WriteHeader(test.status)
+				writer.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			client := New(server.URL, WithRetries(2, time.Millisecond))
+			_, err := client.AssessTransactions(context.Background(), transactionsMock)
+
+			assert.ErrorIs(t, err, test.want)
+			var apiError *APIError
+			assert.True(t, errors.As(err, &apiError))
+			assert.Equal(t, test.status, apiError.StatusCode)
+			assert.Equal(t, test.attempts, attempts.Load())
+		})
+	}
+}
+
+func TestAssessTransactions_RetriesThenSucceeds(t *testing.T) {
+	var attempts atomic.Int32
+	idempotencyKeys := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		idempotencyKeys <- request.Header.Get(IdempotencyKeyHeader)
+		// first attempt fails, second one answers
+		if attempts.Add(1) == 1 {
+			writer.Header().Set("Retry-After", "0")
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "secret", request.Header.Get(APIKeyHeader))
+		writer.Write([]byte(`{"risk_ratings": ["low", "medium"]}`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL, WithRetries(1, time.Millisecond), WithAPIKey("secret"), WithTimeout(time.Second))
+	got, err := client.AssessTransactions(context.Background(), transactionsMock)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Result{
+		{Transaction: transactionsMock[0], Risk: domain.LOW},
+		{Transaction: transactionsMock[1], Risk: domain.MEDIUM},
+	}, got)
+	assert.Equal(t, int32(2), attempts.Load())
+	// both attempts carry the same key, another call gets its own
+	firstKey := <-idempotencyKeys
+	assert.NotEmpty(t, firstKey)
+	assert.Equal(t, firstKey, <-idempotencyKeys)
+	_, err = client.AssessTransactions(context.Background(), transactionsMock)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstKey, <-idempotencyKeys)
+}
+
+func TestAssessTransactions_RatingsMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Write([]byte(`{"risk_ratings": ["low"]}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).AssessTransactions(context.Background(), transactionsMock)
+	assert.Error(t, err)
+}
+
+func TestWithTimeout_CopiesHTTPClient(t *testing.T) {
+	shared := &http.Client{Timeout: time.Minute}
+	client := New("http://localhost:9090", WithHTTPClient(shared), WithTimeout(time.Second))
+	assert.Equal(t, time.Second, client.httpClient.Timeout)
+	assert.Equal(t, time.Minute, shared.Timeout)
+}
+
+func TestNewAPIError_RetryAfter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Retry-After", "3")
+	recorder.WriteHeader(http.StatusTooManyRequests)
+	recorder.WriteString("too many requests")
+
+	got := newAPIError(recorder.Result())
+	assert.Equal(t, &APIError{StatusCode: http.StatusTooManyRequests, Message: "too many requests", RetryAfter: 3 * time.Second}, got)
+}
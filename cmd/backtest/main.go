@@ -0,0 +1,67 @@
+// Command backtest replays a labelled transactions file through the risk rules and reports how they performed.
+//
+//	go run ./cmd/backtest -config rules.json labelled.json
+//	go run ./cmd/backtest -sweep 'merchant.new_merchant_amount_us_cents=[100000,300000,500000]' labelled.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"transactionriskassessment/backtest"
+	"transactionriskassessment/engine"
+)
+
+func main() {
+	configPath := flag.String("config", "", "rules configuration file, the default rules when empty")
+	batchSize := flag.Int("batch", 0, "transactions assessed per request, the whole file when 0")
+	sweep := flag.String("sweep", "", "setting=[value1,value2,...], a JSON array, to also report precision and recall for each value")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: backtest [flags] labelled.json\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := engine.DefaultConfig()
+	if *configPath != "" {
+		var err error
+		if config, err = engine.LoadConfig(*configPath); err != nil {
+			log.Fatalf("loading rules configuration: %v", err)
+		}
+	}
+	transactions, err := backtest.LoadLabelled(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("loading labelled transactions: %v", err)
+	}
+
+	ctx := context.Background()
+	report, err := backtest.Run(ctx, config, transactions, *batchSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := backtest.WriteReport(os.Stdout, report); err != nil {
+		log.Fatal(err)
+	}
+
+	if *sweep == "" {
+		return
+	}
+	setting, values, err := backtest.ParseSweep(*sweep)
+	if err != nil {
+		log.Fatal(err)
+	}
+	results, err := backtest.Sweep(ctx, config, setting, values, transactions, *batchSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println()
+	if err := backtest.WriteSweep(os.Stdout, setting, results); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// currency of the amounts received by the API, whose field is amount_us_cents
+const USDollar = "USD"
+
+// digits after the decimal separator in the decimal form of an amount, cents of the dollar
+const minorUnitDigits = 2
+
+var (
+	// ErrMoneyOverflow is returned when a sum does not fit in the minor units
+	ErrMoneyOverflow = errors.New("amount overflows")
+	// ErrCurrencyMismatch is returned when adding amounts of different currencies
+	ErrCurrencyMismatch = errors.New("amounts have different currencies")
+)
+
+// Money is an amount in the minor units of its currency, e.g. 1050 USD is 10.50 dollars.
+// The zero value has no currency and can be added to any amount
+type Money struct {
+	MinorUnits int64
+	Currency   string
+}
+
+// USD returns the amount of US dollar cents
+func USD(cents int64) Money {
+	return Money{MinorUnits: cents, Currency: USDollar}
+}
+
+// Add returns the sum of both amounts, or an error when it overflows or the currencies differ
+func (money Money) Add(other Money) (Money, error) {
+	currency := money.Currency
+	switch {
+	case currency == "":
+		currency = other.Currency
+	case other.Currency != "" && other.Currency != currency:
+		return money, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, money.Currency, other.Currency)
+	}
+
+	if (other.MinorUnits > 0 && money.MinorUnits > math.MaxInt64-other.MinorUnits) ||
+		(other.MinorUnits < 0 && money.MinorUnits < math.MinInt64-other.MinorUnits) {
+		return money, fmt.Errorf("%w: %s + %s", ErrMoneyOverflow, money, other)
+	}
+	return Money{MinorUnits: money.MinorUnits + other.MinorUnits, Currency: currency}, nil
+}
+
+// Sub returns the difference of both amounts, or an error when it overflows or the currencies differ
+func (money Money) Sub(other Money) (Money, error) {
+	if other.MinorUnits == math.MinInt64 {
+		return money, fmt.Errorf("%w: %s - %s", ErrMoneyOverflow, money, other)
+	}
+	return money.Add(Money{MinorUnits: -other.MinorUnits, Currency: other.Currency})
+}
+
+// String returns the decimal form of the amount followed by its currency, e.g. "10.50 USD"
+func (money Money) String() string {
+	units := strconv.FormatInt(money.MinorUnits, 10)
+	sign := ""
+	if units[0] == '-' {
+		sign, units = "-", units[1:]
+	}
+	if len(units) <= minorUnitDigits {
+		units = strings.Repeat("0", minorUnitDigits-len(units)+1) + units
+	}
+	decimal := sign + units[:len(units)-minorUnitDigits] + "." + units[len(units)-minorUnitDigits:]
+	if money.Currency == "" {
+		return decimal
+	}
+	return decimal + " " + money.Currency
+}
+
+// ParseMoney reads a decimal amount of the currency, e.g. "10.5" or "-3", with at most two decimals
+func ParseMoney(decimal, currency string) (Money, error) {
+	invalid := fmt.Errorf("invalid amount %q, expected a decimal with at most %d decimals", decimal, minorUnitDigits)
+
+	whole, fraction, hasFraction := strings.Cut(decimal, ".")
+	if hasFraction && (fraction == "" || len(fraction) > minorUnitDigits) {
+		return Money{}, invalid
+	}
+	negative := strings.HasPrefix(whole, "-")
+	whole = strings.TrimPrefix(whole, "-")
+	if whole == "" || strings.ContainsAny(whole+fraction, "+-_") {
+		return Money{}, invalid
+	}
+
+	// scaled to minor units by appending the missing decimals, so no float is ever involved
+	digits := whole + fraction + strings.Repeat("0", minorUnitDigits-len(fraction))
+	if negative {
+		digits = "-" + digits
+	}
+	minorUnits, err := strconv.ParseInt(digits, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return Money{}, fmt.Errorf("%w: %q", ErrMoneyOverflow, decimal)
+	}
+	if err != nil {
+		return Money{}, invalid
+	}
+	return Money{MinorUnits: minorUnits, Currency: currency}, nil
+}
+
+// MarshalJSON writes the minor units as an integer, the currency is told by the field name
+func (money Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(money.MinorUnits, 10)), nil
+}
+
+// UnmarshalJSON reads US dollars, from an integer of cents, e.g. 1050, or a decimal string of dollars, e.g. "10.50"
+func (money *Money) UnmarshalJSON(content []byte) error {
+	// as for other fields, null leaves the amount unchanged
+	if string(content) == "null" {
+		return nil
+	}
+	if bytes.HasPrefix(content, []byte(`"`)) {
+		var decimal string
+		if err := json.Unmarshal(content, &decimal); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(decimal, USDollar)
+		if err != nil {
+			return err
+		}
+		*money = parsed
+		return nil
+	}
+
+	cents, err := strconv.ParseInt(string(content), 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("%w: %s", ErrMoneyOverflow, content)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid amount %s, expected whole cents or a decimal string", content)
+	}
+	*money = USD(cents)
+	return nil
+}
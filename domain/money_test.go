@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoneyAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		money   Money
+		other   Money
+		want    Money
+		wantErr error
+	}{
+		{name: "same currency", money: USD(150), other: USD(-50), want: USD(100)},
+		{name: "zero value takes the other currency", money: Money{}, other: USD(7), want: USD(7)},
+		{name: "adding the zero value", money: USD(7), other: Money{}, want: USD(7)},
+		{name: "overflow", money: USD(math.MaxInt64), other: USD(1), wantErr: ErrMoneyOverflow},
+		{name: "negative overflow", money: USD(math.MinInt64), other: USD(-1), wantErr: ErrMoneyOverflow},
+		{name: "different currencies", money: USD(1), other: Money{MinorUnits: 1, Currency: "EUR"}, wantErr: ErrCurrencyMismatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.money.Add(test.other)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestMoneySub(t *testing.T) {
+	got, err := USD(100).Sub(USD(150))
+	assert.NoError(t, err)
+	assert.Equal(t, USD(-50), got)
+
+	_, err = USD(0).Sub(USD(math.MinInt64))
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+	_, err = USD(math.MinInt64).Sub(USD(1))
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "10.50 USD", USD(1050).String())
+	assert.Equal(t, "0.05 USD", USD(5).String())
+	assert.Equal(t, "-0.05 USD", USD(-5).String())
+	assert.Equal(t, "-12.00 USD", USD(-1200).String())
+	assert.Equal(t, "0.00", Money{}.String())
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		decimal string
+		want    Money
+		wantErr string
+	}{
+		{decimal: "10.50", want: USD(1050)},
+		{decimal: "10.5", want: USD(1050)},
+		{decimal: "10", want: USD(1000)},
+		{decimal: "-0.01", want: USD(-1)},
+		{decimal: "0.001", wantErr: "invalid amount"},
+		{decimal: "10.", wantErr: "invalid amount"},
+		{decimal: ".5", wantErr: "invalid amount"},
+		{decimal: "", wantErr: "invalid amount"},
+		{decimal: "+1", wantErr: "invalid amount"},
+		{decimal: "1-2", wantErr: "invalid amount"},
+		{decimal: "1e3", wantErr: "invalid amount"},
+		{decimal: "92233720368547758.08", wantErr: "amount overflows"},
+	}
+	for _, test := range tests {
+		t.Run(test.decimal, func(t *testing.T) {
+			got, err := ParseMoney(test.decimal, USDollar)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var transaction Transaction
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount_us_cents": 1050}`), &transaction))
+	assert.Equal(t, USD(1050), transaction.Amount)
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount_us_cents": "10.50"}`), &transaction))
+	assert.Equal(t, USD(1050), transaction.Amount)
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount_us_cents": null}`), &transaction))
+	assert.Equal(t, USD(1050), transaction.Amount)
+
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"amount_us_cents": 10.5}`), &transaction), "expected whole cents or a decimal string")
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount_us_cents": 9223372036854775808}`), &transaction), ErrMoneyOverflow)
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"amount_us_cents": "ten"}`), &transaction), "invalid amount")
+
+	content, err := json.Marshal(Transaction{Amount: USD(1050)})
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"amount_us_cents":1050`)
+}
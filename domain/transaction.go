@@ -1,6 +1,10 @@
 package domain
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	mapset "github.com/deckarep/golang-set/v2"
 )
 
@@ -13,6 +17,12 @@ type TransactionsByPosition []Transaction
 // alias for type to better legibility
 type TransactionsPerUserMap map[uint]mapset.Set[Transaction]
 
+// relates each card id to the set of user ids that used it
+type UsersPerCardMap map[uint]mapset.Set[uint]
+
+// relates each IP address to the set of user ids that used it
+type UsersPerIPMap map[string]mapset.Set[uint]
+
 // risk enum
 const (
 	LOW RiskLevel = iota
@@ -42,17 +52,131 @@ func (risk RiskLevel) String() string {
 	return "unknown"
 }
 
+// converts the string returned by the API back to its risk level
+func ParseRiskLevel(risk string) (RiskLevel, error) {
+	for _, level := range []RiskLevel{LOW, MEDIUM, HIGH} {
+		if level.String() == risk {
+			return level, nil
+		}
+	}
+	return LOW, fmt.Errorf("unknown risk level %q", risk)
+}
+
+// TransactionType tells how the money moved
+type TransactionType string
+
+// transaction types, transactions without one are purchases
+const (
+	Purchase       TransactionType = "purchase"
+	Refund         TransactionType = "refund"
+	Reversal       TransactionType = "reversal"
+	CashWithdrawal TransactionType = "cash_withdrawal"
+	Transfer       TransactionType = "transfer"
+)
+
+// TransactionTypes lists the known types
+var TransactionTypes = []TransactionType{Purchase, Refund, Reversal, CashWithdrawal, Transfer}
+
+// IsKnown tells whether the type is one of TransactionTypes or empty
+func (transactionType TransactionType) IsKnown() bool {
+	if transactionType == "" {
+		return true
+	}
+	for _, known := range TransactionTypes {
+		if transactionType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// OrPurchase returns the type, purchase when it is empty
+func (transactionType TransactionType) OrPurchase() TransactionType {
+	if transactionType == "" {
+		return Purchase
+	}
+	return transactionType
+}
+
+// IsCredit tells whether the money goes back to the user, as in refunds and reversals, instead of being spent
+func (transactionType TransactionType) IsCredit() bool {
+	return transactionType == Refund || transactionType == Reversal
+}
+
+// ErrInvalidTransaction is returned for transactions that cannot be assessed
+var ErrInvalidTransaction = errors.New("invalid transaction")
+
 type Transaction struct {
-	TransactionId     uint      `json:"id"`
-	UserId            uint      `json:"user_id"`
-	DollarCentsAmount int       `json:"amount_us_cents"`
-	IdCardUsed        uint      `json:"card_id"`
-	RiskRate          RiskLevel `json:"transaction_risk"`
-	LineNumber        int       `json:"-"`
+	TransactionId uint      `json:"id"`
+	UserId        uint      `json:"user_id"`
+	Amount        Money     `json:"amount_us_cents"`
+	IdCardUsed    uint      `json:"card_id"`
+	RiskRate      RiskLevel `json:"transaction_risk"`
+	LineNumber    int       `json:"-"`
+	// optional, 0 when the merchant is unknown
+	MerchantId uint `json:"merchant_id,omitempty"`
+	// optional ISO 18245 merchant category code, e.g. "7995" for gambling
+	MerchantCategoryCode string `json:"mcc,omitempty"`
+	// optional ISO 3166-1 alpha-2 codes of where the transaction happened and of who issued the card
+	Country     string `json:"country,omitempty"`
+	CardCountry string `json:"card_country,omitempty"`
+	// optional coordinates of where the transaction happened, the center of Country is used when unset
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	// optional moment the transaction happened
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// optional fingerprint of where the transaction came from
+	DeviceId  string `json:"device_id,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// optional, purchase when empty
+	Type TransactionType `json:"type,omitempty"`
+	// id of the transaction a reversal cancels
+	ReferenceId uint `json:"reference_id,omitempty"`
+}
+
+// Validate reports an unknown type or a reversal without the transaction it cancels
+func (transaction Transaction) Validate() error {
+	if !transaction.Type.IsKnown() {
+		return fmt.Errorf("%w %d: unknown type %q", ErrInvalidTransaction, transaction.TransactionId, transaction.Type)
+	}
+	if transaction.Type == Reversal && transaction.ReferenceId == 0 {
+		return fmt.Errorf("%w %d: a reversal needs the reference_id of the transaction it cancels", ErrInvalidTransaction, transaction.TransactionId)
+	}
+	return nil
+}
+
+// Action tells the payment gateway what to do with a transaction
+type Action string
+
+// actions, from the most to the least permissive
+const (
+	Approve Action = "approve"
+	// approve once the cardholder passes a 3-D Secure challenge
+	ApproveWithChallenge Action = "approve_with_challenge"
+	HoldForReview        Action = "hold_for_review"
+	Decline              Action = "decline"
+)
+
+// Actions lists the known actions
+var Actions = []Action{Approve, ApproveWithChallenge, HoldForReview, Decline}
+
+// IsKnown tells whether the action is one of Actions
+func (action Action) IsKnown() bool {
+	for _, known := range Actions {
+		if action == known {
+			return true
+		}
+	}
+	return false
 }
 
 type RiskRateResults struct {
 	RiskRates []string `json:"risk_ratings"`
+	// what to do with each transaction, in the same order, decided from its risk by the decision policy
+	Actions []Action `json:"actions,omitempty"`
+	// version of the rules that assessed the transactions
+	RuleSetVersion string `json:"rule_set_version,omitempty"`
 }
 
 // implementing custom sorting function for transaction
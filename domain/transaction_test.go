@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		transaction Transaction
+		wantErr     string
+	}{
+		{name: "no type", transaction: Transaction{TransactionId: 1}},
+		{name: "known type", transaction: Transaction{TransactionId: 1, Type: CashWithdrawal}},
+		{name: "reversal", transaction: Transaction{TransactionId: 2, Type: Reversal, ReferenceId: 1}},
+		{name: "unknown type", transaction: Transaction{TransactionId: 1, Type: "chargeback"}, wantErr: `invalid transaction 1: unknown type "chargeback"`},
+		{name: "reversal without reference", transaction: Transaction{TransactionId: 2, Type: Reversal}, wantErr: "invalid transaction 2: a reversal needs the reference_id"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.transaction.Validate()
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidTransaction)
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestTransactionType(t *testing.T) {
+	assert.Equal(t, Purchase, TransactionType("").OrPurchase())
+	assert.Equal(t, Refund, Refund.OrPurchase())
+	assert.True(t, Refund.IsCredit())
+	assert.True(t, Reversal.IsCredit())
+	assert.False(t, TransactionType("").IsCredit())
+	assert.False(t, Transfer.IsCredit())
+}
+
+func TestParseRiskLevel(t *testing.T) {
+	for _, level := range []RiskLevel{LOW, MEDIUM, HIGH} {
+		got, err := ParseRiskLevel(level.String())
+		assert.NoError(t, err)
+		assert.Equal(t, level, got)
+	}
+	_, err := ParseRiskLevel("severe")
+	assert.ErrorContains(t, err, `unknown risk level "severe"`)
+}
+
+func TestActionIsKnown(t *testing.T) {
+	for _, action := range Actions {
+		assert.True(t, action.IsKnown())
+	}
+	assert.False(t, Action("").IsKnown())
+	assert.False(t, Action("block").IsKnown())
+}
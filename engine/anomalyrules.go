@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"math"
+	. "transactionriskassessment/domain"
+)
+
+// spendingProfile summarizes the latest amounts of a user
+type spendingProfile struct {
+	count             int
+	mean              float64
+	standardDeviation float64
+}
+
+// computes the mean and population standard deviation of the amounts
+func newSpendingProfile(amounts []Money) spendingProfile {
+	profile := spendingProfile{count: len(amounts)}
+	if profile.count == 0 {
+		return profile
+	}
+
+	for _, amount := range amounts {
+		profile.mean += float64(amount.MinorUnits)
+	}
+	profile.mean /= float64(profile.count)
+
+	var squaredDeviations float64
+	for _, amount := range amounts {
+		squaredDeviations += (float64(amount.MinorUnits) - profile.mean) * (float64(amount.MinorUnits) - profile.mean)
+	}
+	profile.standardDeviation = math.Sqrt(squaredDeviations / float64(profile.count))
+	return profile
+}
+
+// how many standard deviations the amount is above the mean, infinite when the user always spends the same
+func (profile spendingProfile) zScore(amount Money) float64 {
+	deviation := float64(amount.MinorUnits) - profile.mean
+	if profile.standardDeviation == 0 {
+		if deviation > 0 {
+			return math.Inf(1)
+		}
+		return 0
+	}
+	return deviation / profile.standardDeviation
+}
+
+// Compares each transaction amount with the user's own spending profile, built from their latest amounts
+// in the history and earlier in the batch, updating its Risk Level when the z-score is above the limits.
+// Only spending more than usual is flagged, and only once the user has enough amounts to compare with.
+// Refunds and reversals are not spending, they are neither compared nor part of the profile
+func riskPerAmountAnomaly(userTransactions []Transaction, batch *Batch, anomaly AnomalyConfig) {
+	// zero disables the rule
+	if len(userTransactions) == 0 || (anomaly.MediumZScore <= 0 && anomaly.HighZScore <= 0) {
+		return
+	}
+	var amounts []Money
+	if batch.History != nil {
+		amounts = batch.History.UserAmounts(userTransactions[0].UserId)
+	}
+
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		if currentTransaction.Type.IsCredit() {
+			continue
+		}
+
+		// only the latest amounts make the profile
+		if len(amounts) > anomaly.Window {
+			amounts = amounts[len(amounts)-anomaly.Window:]
+		}
+		profile := newSpendingProfile(amounts)
+		amounts = append(amounts, currentTransaction.Amount)
+		if profile.count < anomaly.MinHistory {
+			continue
+		}
+
+		zScore := profile.zScore(currentTransaction.Amount)
+		risk := LOW
+		if anomaly.HighZScore > 0 && zScore > anomaly.HighZScore {
+			risk = HIGH
+		} else if anomaly.MediumZScore > 0 && zScore > anomaly.MediumZScore {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
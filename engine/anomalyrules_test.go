@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// returns the amounts of US cents
+func usdAmounts(cents ...int64) []Money {
+	amounts := make([]Money, len(cents))
+	for index, amount := range cents {
+		amounts[index] = USD(amount)
+	}
+	return amounts
+}
+
+func TestNewSpendingProfile(t *testing.T) {
+	profile := newSpendingProfile(usdAmounts(2, 4, 4, 4, 5, 5, 7, 9))
+	assert.Equal(t, 8, profile.count)
+	assert.Equal(t, 5.0, profile.mean)
+	assert.Equal(t, 2.0, profile.standardDeviation)
+	assert.Equal(t, 2.0, profile.zScore(USD(9)))
+
+	assert.Equal(t, spendingProfile{}, newSpendingProfile(nil))
+
+	// always spending the same, any higher amount is infinitely unusual
+	constant := newSpendingProfile(usdAmounts(2000, 2000))
+	assert.True(t, math.IsInf(constant.zScore(USD(2001)), 1))
+	assert.Equal(t, 0.0, constant.zScore(USD(1000)))
+}
+
+func TestRiskPerAmountAnomaly(t *testing.T) {
+	anomaly := AnomalyConfig{MediumZScore: 3, HighZScore: 5, MinHistory: 4, Window: 6}
+
+	// user 1 usually spends around $20
+	history := NewHistory()
+	usualSpending := mapset.NewSet[Transaction]()
+	for line, amount := range []int64{1900, 2000, 2100, 2000, 1900, 2100} {
+		usualSpending.Add(Transaction{UserId: 1, Amount: USD(amount), LineNumber: line + 1})
+	}
+	history.record(&Batch{Users: TransactionsPerUserMap{1: usualSpending}})
+
+	tests := []struct {
+		name  string
+		batch *Batch
+		args  []Transaction
+		want  []RiskLevel
+	}{
+		{
+			name:  "a $4,000 purchase should return high for a user who spends $20, a $20.50 one low",
+			batch: &Batch{History: history},
+			args: []Transaction{
+				{UserId: 1, Amount: USD(2050)},
+				{UserId: 1, Amount: USD(400000)},
+			},
+			want: []RiskLevel{LOW, HIGH},
+		},
+		{
+			name:  "earlier transactions of the batch should build the profile when there is no history",
+			batch: &Batch{},
+			args: []Transaction{
+				{UserId: 2, Amount: USD(1000)},
+				{UserId: 2, Amount: USD(1200)},
+				{UserId: 2, Amount: USD(800)},
+				{UserId: 2, Amount: USD(1000)},
+				// mean 1000, standard deviation ~141, z-score ~3.5
+				{UserId: 2, Amount: USD(1500)},
+			},
+			want: []RiskLevel{LOW, LOW, LOW, LOW, MEDIUM},
+		},
+		{
+			name:  "fewer amounts than the minimum history should not be evaluated",
+			batch: &Batch{},
+			args: []Transaction{
+				{UserId: 3, Amount: USD(1000)},
+				{UserId: 3, Amount: USD(1000)},
+				{UserId: 3, Amount: USD(1000000)},
+			},
+			want: []RiskLevel{LOW, LOW, LOW},
+		},
+		{
+			name:  "spending less than usual should return low",
+			batch: &Batch{History: history},
+			args:  []Transaction{{UserId: 1, Amount: USD(1)}},
+			want:  []RiskLevel{LOW},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerAmountAnomaly(test.args, test.batch, anomaly)
+			assert.Equal(t, test.want, riskLevels(test.args))
+		})
+	}
+}
+
+func TestRiskPerAmountAnomaly_Disabled(t *testing.T) {
+	args := []Transaction{{Amount: USD(1)}, {Amount: USD(1)}, {Amount: USD(1000000)}}
+	riskPerAmountAnomaly(args, &Batch{}, AnomalyConfig{MinHistory: 2, Window: 10})
+	assert.Equal(t, []RiskLevel{LOW, LOW, LOW}, riskLevels(args))
+}
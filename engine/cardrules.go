@@ -0,0 +1,77 @@
+package engine
+
+import (
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// Checks how many different users used the card of each transaction, in the batch and in the history,
+// updating the transaction Risk Level according to the card sharing thresholds
+func riskPerSharedCard(userTransactions []Transaction, batch *Batch, thresholds UsersThresholds) {
+	// the same card is usually repeated along the user's transactions
+	usersPerCard := make(map[uint]int)
+
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		usersCount, isCounted := usersPerCard[currentTransaction.IdCardUsed]
+		if !isCounted {
+			usersCount = batch.cardUsers(currentTransaction.IdCardUsed).Cardinality()
+			usersPerCard[currentTransaction.IdCardUsed] = usersCount
+		}
+
+		risk := LOW
+		if thresholds.HighRiskUsers > 0 && usersCount > thresholds.HighRiskUsers {
+			risk = HIGH
+		} else if thresholds.MediumRiskUsers > 0 && usersCount > thresholds.MediumRiskUsers {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
+
+// Sums up what was spent with the card, whoever used it, updating each transaction Risk Level
+// according to the card total thresholds
+func riskPerCardTotalAmount(cardTransactions []Transaction, card CardConfig) {
+	totals, err := runningTotals(cardTransactions)
+	if err != nil {
+		// the engine refuses these batches before any rule runs
+		return
+	}
+
+	for transacIndex := range cardTransactions {
+		currentTransaction := &cardTransactions[transacIndex]
+		totalAmount := totals[transacIndex].MinorUnits
+
+		risk := LOW
+		if card.HighTotalAmount > 0 && totalAmount > card.HighTotalAmount {
+			risk = HIGH
+		} else if card.MediumTotalAmount > 0 && totalAmount > card.MediumTotalAmount {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
+
+// Counts the distinct merchants the card was used with up to each transaction, whoever used it,
+// updating the transaction Risk Level according to the card merchants thresholds.
+// Transactions without merchant, and refunds and reversals, are not counted
+func riskPerCardMerchants(cardTransactions []Transaction, card CardConfig) {
+	merchantIdSet := mapset.NewSet[uint]()
+
+	for transacIndex := range cardTransactions {
+		currentTransaction := &cardTransactions[transacIndex]
+		if currentTransaction.MerchantId != 0 && !currentTransaction.Type.IsCredit() {
+			merchantIdSet.Add(currentTransaction.MerchantId)
+		}
+		merchantsCount := merchantIdSet.Cardinality()
+
+		risk := LOW
+		if card.HighRiskMerchants > 0 && merchantsCount > card.HighRiskMerchants {
+			risk = HIGH
+		} else if card.MediumRiskMerchants > 0 && merchantsCount > card.MediumRiskMerchants {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"testing"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskPerSharedCard(t *testing.T) {
+	thresholds := UsersThresholds{MediumRiskUsers: 1, HighRiskUsers: 2}
+	history := NewHistory()
+	history.record(&Batch{CardUsers: UsersPerCardMap{3: mapset.NewSet[uint](7, 8)}})
+
+	tests := []struct {
+		name  string
+		batch *Batch
+		args  []Transaction
+		want  []RiskLevel
+	}{
+		{
+			name: "card used only by this user should return low, shared with another one medium",
+			batch: &Batch{CardUsers: UsersPerCardMap{
+				1: mapset.NewSet[uint](1),
+				2: mapset.NewSet[uint](1, 2),
+			}},
+			args: []Transaction{{UserId: 1, IdCardUsed: 1}, {UserId: 1, IdCardUsed: 2}},
+			want: []RiskLevel{LOW, MEDIUM},
+		},
+		{
+			name:  "card shared by three users in the batch should return high",
+			batch: &Batch{CardUsers: UsersPerCardMap{1: mapset.NewSet[uint](1, 2, 3)}},
+			args:  []Transaction{{UserId: 1, IdCardUsed: 1}},
+			want:  []RiskLevel{HIGH},
+		},
+		{
+			name:  "users seen with the card in the history should count too",
+			batch: &Batch{CardUsers: UsersPerCardMap{3: mapset.NewSet[uint](1)}, History: history},
+			args:  []Transaction{{UserId: 1, IdCardUsed: 3}},
+			want:  []RiskLevel{HIGH},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerSharedCard(test.args, test.batch, thresholds)
+			assert.Equal(t, test.want, riskLevels(test.args))
+		})
+	}
+}
+
+func TestRiskPerSharedCard_Disabled(t *testing.T) {
+	args := []Transaction{{UserId: 1, IdCardUsed: 1}}
+	riskPerSharedCard(args, &Batch{CardUsers: UsersPerCardMap{1: mapset.NewSet[uint](1, 2, 3, 4)}}, UsersThresholds{})
+	assert.Equal(t, LOW, args[0].RiskRate)
+}
+
+func TestRiskPerCardTotalAmount(t *testing.T) {
+	card := CardConfig{MediumTotalAmount: 1000, HighTotalAmount: 2000}
+
+	tests := []struct {
+		name string
+		args []Transaction
+		want []RiskLevel
+	}{
+		{
+			name: "card total above the thresholds should return medium, then high, whoever used it",
+			args: []Transaction{
+				{UserId: 1, IdCardUsed: 1, Amount: USD(800)},
+				{UserId: 2, IdCardUsed: 1, Amount: USD(800)},
+				{UserId: 3, IdCardUsed: 1, Amount: USD(800)},
+			},
+			want: []RiskLevel{LOW, MEDIUM, HIGH},
+		},
+		{
+			name: "refunds should lower the card total",
+			args: []Transaction{
+				{UserId: 1, IdCardUsed: 1, Amount: USD(800)},
+				{UserId: 1, IdCardUsed: 1, Amount: USD(500), Type: Refund},
+				{UserId: 2, IdCardUsed: 1, Amount: USD(800)},
+			},
+			want: []RiskLevel{LOW, LOW, MEDIUM},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerCardTotalAmount(test.args, card)
+			assert.Equal(t, test.want, riskLevels(test.args))
+		})
+	}
+}
+
+func TestRiskPerCardMerchants(t *testing.T) {
+	card := CardConfig{MediumRiskMerchants: 1, HighRiskMerchants: 2}
+
+	tests := []struct {
+		name string
+		args []Transaction
+		want []RiskLevel
+	}{
+		{
+			name: "card used with a second and a third merchant should return medium, then high",
+			args: []Transaction{
+				{UserId: 1, IdCardUsed: 1, MerchantId: 1},
+				{UserId: 2, IdCardUsed: 1, MerchantId: 2},
+				{UserId: 1, IdCardUsed: 1, MerchantId: 1},
+				{UserId: 3, IdCardUsed: 1, MerchantId: 3},
+			},
+			want: []RiskLevel{LOW, MEDIUM, MEDIUM, HIGH},
+		},
+		{
+			name: "transactions without merchant and refunds should not be counted",
+			args: []Transaction{
+				{UserId: 1, IdCardUsed: 1, MerchantId: 1},
+				{UserId: 1, IdCardUsed: 1},
+				{UserId: 1, IdCardUsed: 1, MerchantId: 2, Type: Refund},
+			},
+			want: []RiskLevel{LOW, LOW, LOW},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerCardMerchants(test.args, card)
+			assert.Equal(t, test.want, riskLevels(test.args))
+		})
+	}
+}
+
+func TestRiskPerCardRules_Disabled(t *testing.T) {
+	args := []Transaction{{IdCardUsed: 1, Amount: USD(1000000), MerchantId: 1}, {IdCardUsed: 1, Amount: USD(1000000), MerchantId: 2}}
+	riskPerCardTotalAmount(args, CardConfig{})
+	riskPerCardMerchants(args, CardConfig{})
+	assert.Equal(t, []RiskLevel{LOW, LOW}, riskLevels(args))
+}
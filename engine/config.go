@@ -0,0 +1,381 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+
+	. "transactionriskassessment/domain"
+)
+
+// AmountThresholds are the limits, in US cents, above which a transaction is medium or high risk
+type AmountThresholds struct {
+	Medium int64 `json:"medium_us_cents"`
+	High   int64 `json:"high_us_cents"`
+}
+
+// MerchantConfig configures the rules based on where the money was spent
+type MerchantConfig struct {
+	// merchant category codes that are always high risk
+	HighRiskCategories []string `json:"high_risk_categories"`
+	// first transaction of a user with a merchant above this amount is medium risk, 0 disables the rule
+	NewMerchantAmount int64 `json:"new_merchant_amount_us_cents"`
+	// amount thresholds applied only to transactions of the category, keyed by merchant category code
+	CategoryThresholds map[string]AmountThresholds `json:"category_thresholds"`
+}
+
+// GeolocationConfig configures the rules based on where the transaction happened
+type GeolocationConfig struct {
+	// transaction country differing from the card issuing country is medium risk
+	CountryMismatch bool `json:"country_mismatch"`
+	// speed needed between two consecutive transactions of a user above which the later one is high risk, 0 disables the rule
+	MaxTravelSpeedKmh float64 `json:"max_travel_speed_kmh"`
+	// transactions closer than this are never considered impossible travel
+	MinTravelDistanceKm float64 `json:"min_travel_distance_km"`
+}
+
+// UsersThresholds are the number of distinct users sharing something (a card, an IP address) above which
+// it is medium or high risk, counting the batch and, when the engine has a history, previous ones
+type UsersThresholds struct {
+	// shared by more users than this is medium risk, 0 disables it
+	MediumRiskUsers int `json:"medium_risk_users"`
+	// shared by more users than this is high risk, 0 disables it
+	HighRiskUsers int `json:"high_risk_users"`
+}
+
+// CardConfig configures the rules over every transaction of the batch made with the same card, whoever made it
+type CardConfig struct {
+	// what was spent with the card up to the transaction above this is medium risk, 0 disables it
+	MediumTotalAmount int64 `json:"medium_total_us_cents"`
+	// what was spent with the card up to the transaction above this is high risk, 0 disables it
+	HighTotalAmount int64 `json:"high_total_us_cents"`
+	// card used with more distinct merchants than this, up to the transaction, is medium risk, 0 disables it
+	MediumRiskMerchants int `json:"medium_risk_merchants"`
+	// card used with more distinct merchants than this, up to the transaction, is high risk, 0 disables it
+	HighRiskMerchants int `json:"high_risk_merchants"`
+}
+
+// DeviceConfig configures the rules based on the device and IP address the transaction came from
+type DeviceConfig struct {
+	// transaction from a device the user was never seen with is medium risk
+	NewDevice bool `json:"new_device"`
+	// how many users may come from the same IP address
+	UsersPerIP UsersThresholds `json:"users_per_ip"`
+	// transactions from these CIDR ranges are high risk
+	BlockedNetworks []netip.Prefix `json:"blocked_networks"`
+	// file with more CIDR ranges, one per line, read by LoadConfig. Relative to the configuration file
+	IPBlocklistFile string `json:"ip_blocklist_file"`
+}
+
+// AnomalyConfig configures the rule comparing each amount with the user's own spending profile
+type AnomalyConfig struct {
+	// amounts more standard deviations above the user's mean than this are medium risk, 0 disables it
+	MediumZScore float64 `json:"medium_z_score"`
+	// amounts more standard deviations above the user's mean than this are high risk, 0 disables it
+	HighZScore float64 `json:"high_z_score"`
+	// users with fewer previous amounts than this are not evaluated
+	MinHistory int `json:"min_history"`
+	// how many of the latest amounts make the profile
+	Window int `json:"window"`
+}
+
+// ModelConfig configures the rule scoring transactions with a model trained offline
+type ModelConfig struct {
+	// JSON model file read by LoadConfig, relative to the configuration file. No file disables the rule
+	File string `json:"file"`
+	// fraud probabilities from which a transaction is medium or high risk
+	MediumProbability float64 `json:"medium_probability"`
+	HighProbability   float64 `json:"high_probability"`
+	// model read from File, or set directly, already compiled, when the configuration is built in code
+	Model *Model `json:"-"`
+}
+
+// FeedbackConfig configures the rule based on the fraud confirmed by analysts, see WithFraudLabels
+type FeedbackConfig struct {
+	// risk, "low", "medium" or "high", of transactions made with a card involved in confirmed fraud. "low" disables it
+	FraudCardRisk string `json:"fraud_card_risk"`
+	// risk of transactions made by a user involved in confirmed fraud. "low" disables it
+	FraudUserRisk string `json:"fraud_user_risk"`
+}
+
+// Config holds the settings of the configurable rules, usually loaded from a JSON file by LoadConfig
+type Config struct {
+	Merchant    MerchantConfig    `json:"merchant"`
+	Geolocation GeolocationConfig `json:"geolocation"`
+	CardSharing UsersThresholds   `json:"card_sharing"`
+	Card        CardConfig        `json:"card"`
+	Device      DeviceConfig      `json:"device"`
+	Anomaly     AnomalyConfig     `json:"anomaly"`
+	Model       ModelConfig       `json:"model"`
+	Feedback    FeedbackConfig    `json:"feedback"`
+	// amount thresholds applied only to transactions of the type, keyed by type, e.g. "cash_withdrawal"
+	TypeThresholds map[TransactionType]AmountThresholds `json:"type_thresholds"`
+	// rules written as expressions, evaluated after all the others in the given order
+	ExpressionRules []ExpressionRule `json:"expression_rules"`
+	// policy table turning the assessed risks into actions, the first matching row applies
+	Decisions []DecisionRule `json:"decisions"`
+}
+
+// DefaultConfig returns the settings used when no configuration is given. Only the amount and card rules the API
+// always had are on, the merchant, geolocation, card sharing, device and anomaly rules are off until configured,
+// so upgrading does not change the ratings of existing clients. Their other settings are the values used once enabled
+func DefaultConfig() Config {
+	return Config{
+		Geolocation: GeolocationConfig{
+			MinTravelDistanceKm: 100,
+		},
+		Anomaly: AnomalyConfig{
+			MinHistory: 10,
+			Window:     50,
+		},
+		Model: ModelConfig{
+			MediumProbability: 0.5,
+			HighProbability:   0.8,
+		},
+		// only applies to the labels sent to POST /feedback, so it changes nothing for clients not sending them
+		Feedback: FeedbackConfig{
+			// a card used in fraud is likely compromised, while the user may be its victim
+			FraudCardRisk: "high",
+			FraudUserRisk: "medium",
+		},
+		Decisions: DefaultDecisions(),
+	}
+}
+
+// LoadConfig reads a JSON configuration file, settings missing from the file keep their default value
+func LoadConfig(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return DefaultConfig(), err
+	}
+	config, err := ParseConfig(content, filepath.Dir(path))
+	if err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig reads a JSON configuration, settings missing from it keep their default value.
+// The files it names, the IP blocklist and the model, are read relative to directory
+func ParseConfig(content []byte, directory string) (Config, error) {
+	config := DefaultConfig()
+	// arrays are decoded over the elements already there, the rows given would keep the fields of the default rows
+	// they leave out
+	config.Decisions = nil
+	if err := json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("parsing: %w", err)
+	}
+	if config.Decisions == nil {
+		config.Decisions = DefaultDecisions()
+	}
+	if err := config.Validate(); err != nil {
+		return config, fmt.Errorf("validating: %w", err)
+	}
+
+	if config.Device.IPBlocklistFile != "" {
+		networks, err := LoadIPBlocklist(relativeTo(directory, config.Device.IPBlocklistFile))
+		if err != nil {
+			return config, err
+		}
+		config.Device.BlockedNetworks = append(config.Device.BlockedNetworks, networks...)
+	}
+	if config.Model.File != "" {
+		var err error
+		if config.Model.Model, err = LoadModel(relativeTo(directory, config.Model.File)); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// Version identifies the settings, the same configuration always has the same version
+func (config Config) Version() string {
+	// the model is not encoded, only its name and the file it came from
+	content, err := json.Marshal(struct {
+		Config
+		ModelName string `json:"model_name"`
+	}{Config: config, ModelName: modelName(config.Model.Model)})
+	if err != nil {
+		// every setting is encodable, so it cannot happen
+		panic(err)
+	}
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+func modelName(model *Model) string {
+	if model == nil {
+		return ""
+	}
+	return model.Name
+}
+
+// resolves a path found in the configuration relative to the directory of its file
+func relativeTo(directory, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(directory, path)
+}
+
+// Validate reports settings that would make the rules behave unexpectedly
+func (config Config) Validate() error {
+	if config.Merchant.NewMerchantAmount < 0 {
+		return fmt.Errorf("merchant.new_merchant_amount_us_cents must not be negative")
+	}
+	if config.Geolocation.MaxTravelSpeedKmh < 0 || config.Geolocation.MinTravelDistanceKm < 0 {
+		return fmt.Errorf("geolocation speed and distance must not be negative")
+	}
+	if config.CardSharing.MediumRiskUsers < 0 || config.CardSharing.HighRiskUsers < 0 {
+		return fmt.Errorf("card_sharing users must not be negative")
+	}
+	card := config.Card
+	if card.MediumTotalAmount < 0 || card.HighTotalAmount < 0 || card.MediumRiskMerchants < 0 || card.HighRiskMerchants < 0 {
+		return fmt.Errorf("card thresholds must not be negative")
+	}
+	if card.MediumTotalAmount > 0 && card.HighTotalAmount > 0 && card.MediumTotalAmount > card.HighTotalAmount {
+		return fmt.Errorf("card.medium_total_us_cents is greater than card.high_total_us_cents")
+	}
+	if card.MediumRiskMerchants > 0 && card.HighRiskMerchants > 0 && card.MediumRiskMerchants > card.HighRiskMerchants {
+		return fmt.Errorf("card.medium_risk_merchants is greater than card.high_risk_merchants")
+	}
+	if config.Device.UsersPerIP.MediumRiskUsers < 0 || config.Device.UsersPerIP.HighRiskUsers < 0 {
+		return fmt.Errorf("device.users_per_ip users must not be negative")
+	}
+	anomaly := config.Anomaly
+	if anomaly.MediumZScore < 0 || anomaly.HighZScore < 0 {
+		return fmt.Errorf("anomaly z-scores must not be negative")
+	}
+	if anomaly.MediumZScore > 0 && anomaly.HighZScore > 0 && anomaly.MediumZScore > anomaly.HighZScore {
+		return fmt.Errorf("anomaly.medium_z_score is greater than anomaly.high_z_score")
+	}
+	if anomaly.Window < 1 || anomaly.Window > maxUserAmounts {
+		return fmt.Errorf("anomaly.window must be between 1 and %d", maxUserAmounts)
+	}
+	// a single amount has no deviation to compare with
+	if anomaly.MinHistory < 2 || anomaly.MinHistory > anomaly.Window {
+		return fmt.Errorf("anomaly.min_history must be between 2 and anomaly.window")
+	}
+	model := config.Model
+	if model.MediumProbability < 0 || model.HighProbability > 1 || model.MediumProbability > model.HighProbability {
+		return fmt.Errorf("model probabilities must be between 0 and 1, medium_probability not above high_probability")
+	}
+	if _, err := ParseRiskLevel(config.Feedback.FraudCardRisk); err != nil {
+		return fmt.Errorf("feedback.fraud_card_risk: %w", err)
+	}
+	if _, err := ParseRiskLevel(config.Feedback.FraudUserRisk); err != nil {
+		return fmt.Errorf("feedback.fraud_user_risk: %w", err)
+	}
+	for category, thresholds := range config.Merchant.CategoryThresholds {
+		if thresholds.Medium > thresholds.High {
+			return fmt.Errorf("merchant.category_thresholds[%s]: medium threshold is greater than high threshold", category)
+		}
+	}
+	for transactionType, thresholds := range config.TypeThresholds {
+		if !transactionType.IsKnown() || transactionType == "" {
+			return fmt.Errorf("type_thresholds: unknown transaction type %q", transactionType)
+		}
+		if thresholds.Medium > thresholds.High {
+			return fmt.Errorf("type_thresholds[%s]: medium threshold is greater than high threshold", transactionType)
+		}
+	}
+	if err := validateDecisions(config.Decisions); err != nil {
+		return err
+	}
+	ruleNames := make(map[string]bool, len(config.ExpressionRules))
+	for _, expressionRule := range config.ExpressionRules {
+		if _, _, err := expressionRule.compile(); err != nil {
+			return fmt.Errorf("expression_rules: %w", err)
+		}
+		if ruleNames[expressionRule.Name] {
+			return fmt.Errorf("expression_rules: rule %s is defined more than once", expressionRule.Name)
+		}
+		ruleNames[expressionRule.Name] = true
+	}
+	return nil
+}
+
+// Rules returns the README rules followed by the configurable ones, in the order they are evaluated.
+// Expression rules that do not compile are left out, Validate reports them
+func (config Config) Rules() []Rule {
+	merchant := config.Merchant
+	geolocation := config.Geolocation
+	cardSharing := config.CardSharing
+	card := config.Card
+	device := config.Device
+	anomaly := config.Anomaly
+	model := config.Model
+	// invalid risks, reported by Validate, disable the rule
+	fraudCardRisk, _ := ParseRiskLevel(config.Feedback.FraudCardRisk)
+	fraudUserRisk, _ := ParseRiskLevel(config.Feedback.FraudUserRisk)
+	rules := []Rule{
+		{Name: "single_amount", Evaluate: func(userTransactions []Transaction, _ *Batch) { riskPerSingleAmount(userTransactions) }},
+		{Name: "total_amount", Evaluate: func(userTransactions []Transaction, _ *Batch) { riskPerTotalAmount(userTransactions) }},
+		{Name: "multiple_cards", Evaluate: func(userTransactions []Transaction, _ *Batch) { riskPerMultipleCards(userTransactions) }},
+		{Name: "high_risk_category", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+			riskPerMerchantCategory(userTransactions, merchant.HighRiskCategories)
+		}},
+		{Name: "new_merchant_amount", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+			riskPerNewMerchant(userTransactions, merchant.NewMerchantAmount)
+		}},
+		{Name: "category_amount", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+			riskPerCategoryAmount(userTransactions, merchant.CategoryThresholds)
+		}},
+		{Name: "type_amount", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+			riskPerTypeAmount(userTransactions, config.TypeThresholds)
+		}},
+		{Name: "shared_card", Evaluate: func(userTransactions []Transaction, batch *Batch) {
+			riskPerSharedCard(userTransactions, batch, cardSharing)
+		}},
+		{Name: "card_total_amount", GroupBy: ByCard, Evaluate: func(cardTransactions []Transaction, _ *Batch) {
+			riskPerCardTotalAmount(cardTransactions, card)
+		}},
+		{Name: "card_merchants", GroupBy: ByCard, Evaluate: func(cardTransactions []Transaction, _ *Batch) {
+			riskPerCardMerchants(cardTransactions, card)
+		}},
+		{Name: "impossible_travel", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+			riskPerImpossibleTravel(userTransactions, geolocation.MaxTravelSpeedKmh, geolocation.MinTravelDistanceKm)
+		}},
+	}
+	if geolocation.CountryMismatch {
+		rules = append(rules, Rule{Name: "country_mismatch", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+			riskPerCountryMismatch(userTransactions)
+		}})
+	}
+	if device.NewDevice {
+		rules = append(rules, Rule{Name: "new_device", Evaluate: riskPerNewDevice})
+	}
+	rules = append(rules,
+		Rule{Name: "shared_ip", Evaluate: func(userTransactions []Transaction, batch *Batch) {
+			riskPerSharedIP(userTransactions, batch, device.UsersPerIP)
+		}},
+		Rule{Name: "blocked_ip", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+			riskPerBlockedIP(userTransactions, device.BlockedNetworks)
+		}},
+		Rule{Name: "amount_anomaly", Evaluate: func(userTransactions []Transaction, batch *Batch) {
+			riskPerAmountAnomaly(userTransactions, batch, anomaly)
+		}},
+		Rule{Name: "confirmed_fraud", Evaluate: func(userTransactions []Transaction, batch *Batch) {
+			riskPerConfirmedFraud(userTransactions, batch, fraudCardRisk, fraudUserRisk)
+		}},
+	)
+	if model.Model != nil {
+		rules = append(rules, Rule{Name: "model", Evaluate: func(userTransactions []Transaction, batch *Batch) {
+			riskPerModel(userTransactions, batch, model)
+		}})
+	}
+	for _, expressionRule := range config.ExpressionRules {
+		expression, risk, err := expressionRule.compile()
+		if err != nil {
+			continue
+		}
+		rules = append(rules, Rule{Name: "expression:" + expressionRule.Name, Evaluate: func(userTransactions []Transaction, batch *Batch) {
+			riskPerExpression(userTransactions, batch, expression, risk)
+		}})
+	}
+	return rules
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writes content to a temporary file, returning its path
+func writeTempConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    func() Config
+		wantErr bool
+	}{
+		{
+			name:    "missing settings should keep their defaults",
+			content: `{"merchant": {"new_merchant_amount_us_cents": 100}}`,
+			want: func() Config {
+				config := DefaultConfig()
+				config.Merchant.NewMerchantAmount = 100
+				return config
+			},
+		},
+		{
+			name:    "category thresholds should be read by merchant category code",
+			content: `{"merchant": {"category_thresholds": {"5812": {"medium_us_cents": 10, "high_us_cents": 20}}}}`,
+			want: func() Config {
+				config := DefaultConfig()
+				config.Merchant.CategoryThresholds = map[string]AmountThresholds{"5812": {Medium: 10, High: 20}}
+				return config
+			},
+		},
+		{
+			name:    "medium threshold above high should fail validation",
+			content: `{"merchant": {"category_thresholds": {"5812": {"medium_us_cents": 30, "high_us_cents": 20}}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "type thresholds should be read by transaction type",
+			content: `{"type_thresholds": {"cash_withdrawal": {"medium_us_cents": 10, "high_us_cents": 20}}}`,
+			want: func() Config {
+				config := DefaultConfig()
+				config.TypeThresholds = map[TransactionType]AmountThresholds{CashWithdrawal: {Medium: 10, High: 20}}
+				return config
+			},
+		},
+		{
+			name:    "unknown transaction type should fail validation",
+			content: `{"type_thresholds": {"withdrawal": {"medium_us_cents": 10, "high_us_cents": 20}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "card thresholds should be read",
+			content: `{"card": {"medium_total_us_cents": 100, "high_total_us_cents": 200, "medium_risk_merchants": 3, "high_risk_merchants": 5}}`,
+			want: func() Config {
+				config := DefaultConfig()
+				config.Card = CardConfig{MediumTotalAmount: 100, HighTotalAmount: 200, MediumRiskMerchants: 3, HighRiskMerchants: 5}
+				return config
+			},
+		},
+		{
+			name:    "card medium merchants above high should fail validation",
+			content: `{"card": {"medium_risk_merchants": 5, "high_risk_merchants": 3}}`,
+			wantErr: true,
+		},
+		{
+			name:    "decisions should replace the default policy",
+			content: `{"decisions": [{"risk": "high", "min_amount_us_cents": 500, "action": "decline"}]}`,
+			want: func() Config {
+				config := DefaultConfig()
+				config.Decisions = []DecisionRule{{Risk: "high", MinAmount: 500, Action: Decline}}
+				return config
+			},
+		},
+		{
+			name:    "decisions should be read row by row, without the fields of the default rows",
+			content: `{"decisions": [{"risk": "low", "action": "approve"}, {"risk": "medium", "action": "decline"}, {"risk": "high", "action": "decline"}]}`,
+			want: func() Config {
+				config := DefaultConfig()
+				config.Decisions = []DecisionRule{{Risk: "low", Action: Approve}, {Risk: "medium", Action: Decline}, {Risk: "high", Action: Decline}}
+				return config
+			},
+		},
+		{
+			name:    "unknown decision action should fail validation",
+			content: `{"decisions": [{"risk": "high", "action": "block"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown feedback risk should fail validation",
+			content: `{"feedback": {"fraud_card_risk": "severe"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON should fail",
+			content: `{"merchant": `,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := LoadConfig(writeTempConfig(t, test.content))
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want(), got)
+		})
+	}
+}
+
+func TestLoadConfig_IPBlocklistFile(t *testing.T) {
+	configPath := writeTempConfig(t, `{"device": {"blocked_networks": ["192.0.2.0/24"], "ip_blocklist_file": "blocklist.txt"}}`)
+	// relative to the configuration file
+	if err := os.WriteFile(filepath.Join(filepath.Dir(configPath), "blocklist.txt"), []byte("203.0.113.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadConfig(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24"), netip.MustParsePrefix("203.0.113.0/24")}, got.Device.BlockedNetworks)
+}
+
+func TestLoadConfig_ModelFile(t *testing.T) {
+	modelPath, err := filepath.Abs(filepath.Join("testdata", "model.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadConfig(writeTempConfig(t, `{"model": {"file": "`+filepath.ToSlash(modelPath)+`", "high_probability": 0.9}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "example-gbm", got.Model.Model.Name)
+	assert.Equal(t, 0.9, got.Model.HighProbability)
+	assert.Equal(t, "model", got.Rules()[len(got.Rules())-1].Name)
+
+	_, err = LoadConfig(writeTempConfig(t, `{"model": {"file": "missing.json"}}`))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoadConfig_ExpressionRules(t *testing.T) {
+	got, err := LoadConfig(writeTempConfig(t, `{"expression_rules": [{"name": "new_card_amount", "when": ["amount_us_cents > 300000", "and card_first_use"], "risk": "high"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "expression:new_card_amount", got.Rules()[len(got.Rules())-1].Name)
+
+	_, err = LoadConfig(writeTempConfig(t, `{"expression_rules": [{"name": "broken", "when": ["amount_us_cents > 300000", "and card_used"], "risk": "high"}]}`))
+	assert.ErrorContains(t, err, `expression_rules: rule broken: line 2 column 5: unknown variable "card_used"`)
+
+	_, err = LoadConfig(writeTempConfig(t, `{"expression_rules": [{"name": "twice", "when": "true", "risk": "high"}, {"name": "twice", "when": "false", "risk": "high"}]}`))
+	assert.ErrorContains(t, err, "rule twice is defined more than once")
+}
+
+func TestLoadConfig_MissingFile(t *testing.T) {
+	_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestParseConfig_RelativeFiles(t *testing.T) {
+	directory := t.TempDir()
+	if err := os.WriteFile(filepath.Join(directory, "blocklist.txt"), []byte("203.0.113.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// files are read relative to the directory given, there is no configuration file
+	got, err := ParseConfig([]byte(`{"device": {"ip_blocklist_file": "blocklist.txt"}}`), directory)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}, got.Device.BlockedNetworks)
+
+	_, err = ParseConfig([]byte(`{"card_sharing": {"medium_risk_users": -1}}`), directory)
+	assert.ErrorContains(t, err, "validating")
+}
+
+func TestDefaultConfig_OnlyOriginalRules(t *testing.T) {
+	// a gambling merchant, another country, a card shared by three users from the same IP address and new devices
+	transactions := []Transaction{
+		{TransactionId: 1, UserId: 1, Amount: USD(100), IdCardUsed: 7, MerchantCategoryCode: "7995", Country: "BR", CardCountry: "US", IPAddress: "192.0.2.1", DeviceId: "a"},
+		{TransactionId: 2, UserId: 2, Amount: USD(100), IdCardUsed: 7, MerchantId: 3, IPAddress: "192.0.2.1", DeviceId: "b"},
+		{TransactionId: 3, UserId: 3, Amount: USD(400000), IdCardUsed: 7, MerchantId: 4, IPAddress: "192.0.2.1", DeviceId: "c"},
+	}
+	got, err := New(WithHistory(NewHistory())).Assess(context.Background(), transactions)
+	assert.NoError(t, err)
+	// rated as before the newer rules existed
+	assert.Equal(t, []string{"low", "low", "low"}, got.RiskRates)
+}
+
+func TestConfigRules_Names(t *testing.T) {
+	var names []string
+	for _, rule := range DefaultConfig().Rules() {
+		names = append(names, rule.Name)
+	}
+	// the rules switched off by a flag are left out, the others match nothing until their thresholds are set
+	assert.Equal(t, []string{"single_amount", "total_amount", "multiple_cards", "high_risk_category", "new_merchant_amount", "category_amount", "type_amount", "shared_card", "card_total_amount", "card_merchants", "impossible_travel", "shared_ip", "blocked_ip", "amount_anomaly", "confirmed_fraud"}, names)
+}
+
+func TestConfigVersion(t *testing.T) {
+	version := DefaultConfig().Version()
+	assert.Regexp(t, `^sha256:[0-9a-f]{12}$`, version)
+	assert.Equal(t, version, DefaultConfig().Version())
+
+	changed := DefaultConfig()
+	changed.Merchant.NewMerchantAmount++
+	assert.NotEqual(t, version, changed.Version())
+
+	withModel := DefaultConfig()
+	withModel.Model.Model = &Model{Name: "example"}
+	assert.NotEqual(t, version, withModel.Version())
+}
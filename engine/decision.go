@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+
+	. "transactionriskassessment/domain"
+)
+
+// DecisionRule is a row of the decision policy: transactions of the risk, of at least the amount, get the action
+type DecisionRule struct {
+	// "low", "medium" or "high"
+	Risk string `json:"risk"`
+	// amounts below this, in US cents, do not match the row. 0 matches every amount
+	MinAmount int64  `json:"min_amount_us_cents"`
+	Action    Action `json:"action"`
+}
+
+// DefaultDecisions returns the decision policy used when no configuration is given
+func DefaultDecisions() []DecisionRule {
+	return []DecisionRule{
+		{Risk: "low", Action: Approve},
+		// large amounts are worth an analyst's time, small ones only a challenge to the cardholder
+		{Risk: "medium", MinAmount: 100000, Action: HoldForReview},
+		{Risk: "medium", Action: ApproveWithChallenge},
+		{Risk: "high", Action: Decline},
+	}
+}
+
+// returns the action of the first row matching the transaction, hold for review when no row does
+func decide(decisions []DecisionRule, transaction Transaction) Action {
+	for _, decision := range decisions {
+		risk, err := ParseRiskLevel(decision.Risk)
+		if err != nil {
+			// reported by Validate
+			continue
+		}
+		if risk == transaction.RiskRate && transaction.Amount.MinorUnits >= decision.MinAmount {
+			return decision.Action
+		}
+	}
+	return HoldForReview
+}
+
+// Decide returns the action for each assessment, in the same order, according to the configured decision policy
+func (engine *Engine) Decide(assessments []Assessment) []Action {
+	decisions := engine.config.Decisions
+	// configurations built in code without a policy would otherwise hold every transaction
+	if len(decisions) == 0 {
+		decisions = DefaultDecisions()
+	}
+	var actions []Action
+	for _, assessment := range assessments {
+		actions = append(actions, decide(decisions, assessment.Transaction))
+	}
+	return actions
+}
+
+// reports rows that can never match or give an unknown action
+func validateDecisions(decisions []DecisionRule) error {
+	for index, decision := range decisions {
+		if _, err := ParseRiskLevel(decision.Risk); err != nil {
+			return fmt.Errorf("decisions[%d]: %w", index, err)
+		}
+		if decision.MinAmount < 0 {
+			return fmt.Errorf("decisions[%d]: min_amount_us_cents must not be negative", index)
+		}
+		if !decision.Action.IsKnown() {
+			return fmt.Errorf("decisions[%d]: unknown action %q", index, decision.Action)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecide(t *testing.T) {
+	decisions := DefaultDecisions()
+
+	tests := []struct {
+		name        string
+		transaction Transaction
+		want        Action
+	}{
+		{
+			name:        "low risk should be approved",
+			transaction: Transaction{RiskRate: LOW, Amount: USD(5000000)},
+			want:        Approve,
+		},
+		{
+			name:        "medium risk below the amount should be challenged",
+			transaction: Transaction{RiskRate: MEDIUM, Amount: USD(99999)},
+			want:        ApproveWithChallenge,
+		},
+		{
+			name:        "medium risk from the amount on should be held for review",
+			transaction: Transaction{RiskRate: MEDIUM, Amount: USD(100000)},
+			want:        HoldForReview,
+		},
+		{
+			name:        "high risk should be declined",
+			transaction: Transaction{RiskRate: HIGH, Amount: USD(1)},
+			want:        Decline,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, decide(decisions, test.transaction))
+		})
+	}
+
+	// no row matching, nobody decided, an analyst does
+	assert.Equal(t, HoldForReview, decide([]DecisionRule{{Risk: "low", Action: Approve}}, Transaction{RiskRate: HIGH}))
+}
+
+func TestEngineDecide(t *testing.T) {
+	config := DefaultConfig()
+	config.Decisions = []DecisionRule{{Risk: "low", Action: Approve}, {Risk: "high", Action: HoldForReview}}
+
+	results, err := New(WithConfig(config)).Assess(context.Background(), []Transaction{
+		{TransactionId: 1, UserId: 1, Amount: USD(100), IdCardUsed: 1},
+		{TransactionId: 2, UserId: 2, Amount: USD(1100000), IdCardUsed: 2},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low", "high"}, results.RiskRates)
+	assert.Equal(t, []Action{Approve, HoldForReview}, results.Actions)
+}
+
+func TestValidateDecisions(t *testing.T) {
+	assert.NoError(t, validateDecisions(DefaultDecisions()))
+	assert.ErrorContains(t, validateDecisions([]DecisionRule{{Risk: "severe", Action: Decline}}), "decisions[0]")
+	assert.ErrorContains(t, validateDecisions([]DecisionRule{{Risk: "low", MinAmount: -1, Action: Approve}}), "must not be negative")
+	assert.ErrorContains(t, validateDecisions([]DecisionRule{{Risk: "low", Action: "accept"}}), `unknown action "accept"`)
+}
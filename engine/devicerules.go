@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// identifies the device a transaction came from: its device id or, when missing, its user agent
+func deviceFingerprint(transaction Transaction) string {
+	if transaction.DeviceId != "" {
+		return "device:" + transaction.DeviceId
+	}
+	if transaction.UserAgent != "" {
+		return "agent:" + transaction.UserAgent
+	}
+	return ""
+}
+
+// Raises to medium the risk of transactions coming from a device the user was never seen with, earlier
+// in the batch or in the history. A user's first known device is not considered new, there is nothing to compare with
+func riskPerNewDevice(userTransactions []Transaction, batch *Batch) {
+	if len(userTransactions) == 0 {
+		return
+	}
+	knownDevices := mapset.NewSet[string]()
+	if batch.History != nil {
+		knownDevices = batch.History.UserDevices(userTransactions[0].UserId)
+	}
+
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		fingerprint := deviceFingerprint(*currentTransaction)
+		if fingerprint == "" {
+			continue
+		}
+
+		if knownDevices.Cardinality() > 0 && !knownDevices.Contains(fingerprint) {
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, MEDIUM)
+		}
+		knownDevices.Add(fingerprint)
+	}
+}
+
+// Checks how many different users came from the IP address of each transaction, in the batch and in the history,
+// updating the transaction Risk Level according to the thresholds
+func riskPerSharedIP(userTransactions []Transaction, batch *Batch, thresholds UsersThresholds) {
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		if currentTransaction.IPAddress == "" {
+			continue
+		}
+		usersCount := batch.ipUsers(currentTransaction.IPAddress).Cardinality()
+
+		risk := LOW
+		if thresholds.HighRiskUsers > 0 && usersCount > thresholds.HighRiskUsers {
+			risk = HIGH
+		} else if thresholds.MediumRiskUsers > 0 && usersCount > thresholds.MediumRiskUsers {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
+
+// Raises to high the risk of transactions coming from an IP address inside one of the blocked networks.
+// Missing or malformed IP addresses are skipped
+func riskPerBlockedIP(userTransactions []Transaction, blockedNetworks []netip.Prefix) {
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		address, err := netip.ParseAddr(currentTransaction.IPAddress)
+		if err != nil {
+			continue
+		}
+		// IPv4 addresses written as IPv6 (::ffff:1.2.3.4) must match IPv4 networks
+		address = address.Unmap()
+
+		for _, network := range blockedNetworks {
+			if network.Contains(address) {
+				currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, HIGH)
+				break
+			}
+		}
+	}
+}
+
+// errInvalidBlocklistLine does not quote the line, the file may not be a blocklist and its content must not leak
+var errInvalidBlocklistLine = errors.New("not an IP address or CIDR range")
+
+// LoadIPBlocklist reads a file with one CIDR range per line, e.g. 203.0.113.0/24. Single addresses are
+// accepted as ranges of one, blank lines and lines starting with # are ignored
+func LoadIPBlocklist(path string) ([]netip.Prefix, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var networks []netip.Prefix
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !strings.Contains(line, "/") {
+			address, err := netip.ParseAddr(line)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, errInvalidBlocklistLine)
+			}
+			networks = append(networks, netip.PrefixFrom(address, address.BitLen()))
+			continue
+		}
+		network, err := netip.ParsePrefix(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, errInvalidBlocklistLine)
+		}
+		networks = append(networks, network.Masked())
+	}
+	return networks, scanner.Err()
+}
@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// collects the risk of each transaction, in order
+func riskLevels(transactions []Transaction) []RiskLevel {
+	var levels []RiskLevel
+	for _, transaction := range transactions {
+		levels = append(levels, transaction.RiskRate)
+	}
+	return levels
+}
+
+func TestDeviceFingerprint(t *testing.T) {
+	assert.Equal(t, "device:phone", deviceFingerprint(Transaction{DeviceId: "phone", UserAgent: "Mozilla"}))
+	assert.Equal(t, "agent:Mozilla", deviceFingerprint(Transaction{UserAgent: "Mozilla"}))
+	assert.Equal(t, "", deviceFingerprint(Transaction{}))
+}
+
+func TestRiskPerNewDevice(t *testing.T) {
+	history := NewHistory()
+	history.record(&Batch{Users: TransactionsPerUserMap{1: mapset.NewSet(Transaction{UserId: 1, DeviceId: "phone"})}})
+
+	tests := []struct {
+		name  string
+		batch *Batch
+		args  []Transaction
+		want  []RiskLevel
+	}{
+		{
+			name:  "first device of the batch is the baseline, a second one should return medium",
+			batch: &Batch{},
+			args: []Transaction{
+				{UserId: 2, DeviceId: "laptop"},
+				{UserId: 2, DeviceId: "laptop"},
+				{UserId: 2},
+				{UserId: 2, DeviceId: "tablet"},
+			},
+			want: []RiskLevel{LOW, LOW, LOW, MEDIUM},
+		},
+		{
+			name:  "devices seen in the history should be known, others new",
+			batch: &Batch{History: history},
+			args: []Transaction{
+				{UserId: 1, DeviceId: "phone"},
+				{UserId: 1, DeviceId: "laptop"},
+			},
+			want: []RiskLevel{LOW, MEDIUM},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerNewDevice(test.args, test.batch)
+			assert.Equal(t, test.want, riskLevels(test.args))
+		})
+	}
+}
+
+func TestRiskPerSharedIP(t *testing.T) {
+	batch := &Batch{IPUsers: UsersPerIPMap{
+		"203.0.113.1": mapset.NewSet[uint](1),
+		"203.0.113.2": mapset.NewSet[uint](1, 2),
+		"203.0.113.3": mapset.NewSet[uint](1, 2, 3, 4),
+	}}
+	args := []Transaction{
+		{UserId: 1, IPAddress: "203.0.113.1"},
+		{UserId: 1, IPAddress: "203.0.113.2"},
+		{UserId: 1, IPAddress: "203.0.113.3"},
+		{UserId: 1},
+	}
+
+	riskPerSharedIP(args, batch, UsersThresholds{MediumRiskUsers: 1, HighRiskUsers: 3})
+	assert.Equal(t, []RiskLevel{LOW, MEDIUM, HIGH, LOW}, riskLevels(args))
+}
+
+func TestRiskPerBlockedIP(t *testing.T) {
+	networks := []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24"), netip.MustParsePrefix("2001:db8::/32")}
+	args := []Transaction{
+		{IPAddress: "203.0.113.77"},
+		{IPAddress: "198.51.100.1"},
+		{IPAddress: "::ffff:203.0.113.5"},
+		{IPAddress: "2001:db8::1"},
+		{IPAddress: "not an ip"},
+		{},
+	}
+
+	riskPerBlockedIP(args, networks)
+	assert.Equal(t, []RiskLevel{HIGH, LOW, HIGH, HIGH, LOW, LOW}, riskLevels(args))
+}
+
+func TestLoadIPBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	content := "# known proxies\n203.0.113.0/24\n\n198.51.100.9\n10.1.2.3/8\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadIPBlocklist(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("203.0.113.0/24"),
+		netip.MustParsePrefix("198.51.100.9/32"),
+		// host bits are masked out
+		netip.MustParsePrefix("10.0.0.0/8"),
+	}, got)
+
+	if err := os.WriteFile(path, []byte("203.0.113.0/24\n300.1.1.1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadIPBlocklist(path)
+	assert.ErrorContains(t, err, "blocklist.txt:2")
+	// the line is not quoted, the file may hold anything
+	assert.NotContains(t, err.Error(), "300.1.1.1")
+}
@@ -0,0 +1,301 @@
+// Package engine holds the risk rules and the Engine that applies them, so they can be embedded
+// in any Go service without going through the HTTP API
+package engine
+
+import (
+	"context"
+	"sort"
+
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// Batch gives rules access to the whole request, not only to the user being evaluated
+type Batch struct {
+	// every transaction of the request grouped by user
+	Users TransactionsPerUserMap
+	// every user of the request grouped by the card they used
+	CardUsers UsersPerCardMap
+	// every user of the request grouped by the IP address they came from
+	IPUsers UsersPerIPMap
+	// previous assessments, nil unless the engine was built WithHistory
+	History *History
+	// analysts feedback, nil unless the engine was built WithFraudLabels
+	FraudLabels FraudLabels
+}
+
+// returns the users seen with the card in this batch and, when there is a history, in previous ones
+func (batch *Batch) cardUsers(cardId uint) mapset.Set[uint] {
+	cardUsers := mapset.NewSet[uint]()
+	if batchUsers, isKnownCard := batch.CardUsers[cardId]; isKnownCard {
+		cardUsers = cardUsers.Union(batchUsers)
+	}
+	if batch.History != nil {
+		cardUsers = cardUsers.Union(batch.History.CardUsers(cardId))
+	}
+	return cardUsers
+}
+
+// returns the users seen with the IP address in this batch and, when there is a history, in previous ones
+func (batch *Batch) ipUsers(ipAddress string) mapset.Set[uint] {
+	ipUsers := mapset.NewSet[uint]()
+	if batchUsers, isKnownIP := batch.IPUsers[ipAddress]; isKnownIP {
+		ipUsers = ipUsers.Union(batchUsers)
+	}
+	if batch.History != nil {
+		ipUsers = ipUsers.Union(batch.History.IPUsers(ipAddress))
+	}
+	return ipUsers
+}
+
+// Rule updates the RiskRate of a group of transactions, by default a user's, received ordered by input position.
+// Rules must only raise risks (see greaterRisk), since every rule runs over the same transactions
+type Rule struct {
+	Name string
+	// which transactions the rule receives together, ByUser unless set
+	GroupBy  GroupKey
+	Evaluate func(userTransactions []Transaction, batch *Batch)
+}
+
+// DefaultRules returns the rules built from DefaultConfig, in the order they are evaluated
+func DefaultRules() []Rule {
+	return DefaultConfig().Rules()
+}
+
+// Engine assesses batches of transactions. It holds no state between calls, unless built WithHistory,
+// and is safe for concurrent use
+type Engine struct {
+	config Config
+	// config.Version(), computed once since every assessment is stamped with it
+	version string
+	rules   []Rule
+	history *History
+	// challenger engine, nil unless built WithShadow
+	shadow *Shadow
+	// confirmed fraud, nil unless built WithFraudLabels
+	fraudLabels FraudLabels
+}
+
+// Option changes how an Engine is built
+type Option func(*Engine)
+
+// WithConfig builds the rules from config instead of DefaultConfig
+func WithConfig(config Config) Option {
+	return func(engine *Engine) {
+		engine.config = config
+	}
+}
+
+// WithHistory makes the engine remember every batch it assesses, so rules also consider previous ones
+func WithHistory(history *History) Option {
+	return func(engine *Engine) {
+		engine.history = history
+	}
+}
+
+// WithRules replaces the rules built from the configuration, append to DefaultRules() to keep them
+func WithRules(rules ...Rule) Option {
+	return func(engine *Engine) {
+		engine.rules = rules
+	}
+}
+
+// New creates an Engine evaluating the rules of DefaultConfig unless options say otherwise
+func New(options ...Option) *Engine {
+	engine := &Engine{config: DefaultConfig()}
+	for _, option := range options {
+		option(engine)
+	}
+	// rules given by WithRules take precedence over the configured ones
+	if engine.rules == nil {
+		engine.rules = engine.config.Rules()
+	}
+	engine.version = engine.config.Version()
+	return engine
+}
+
+// Reconfigure returns an engine evaluating the rules of config, sharing the history, the fraud labels and the
+// shadow rules of this one, so switching rules does not forget previous assessments. This engine keeps its rules
+// but is closed, the reconfigured one compares its batches with the shadow rules from now on
+func (engine *Engine) Reconfigure(config Config) *Engine {
+	reconfigured := *engine
+	reconfigured.config = config
+	reconfigured.rules = config.Rules()
+	reconfigured.version = config.Version()
+	if engine.shadow != nil {
+		reconfigured.shadow = startShadow(engine.shadow)
+		engine.Close()
+	}
+	return &reconfigured
+}
+
+// Close stops the goroutine comparing the batches with the shadow rules, once the batches already queued are
+// compared. The ones committed afterwards are reported with ErrShadowStopped. Without a shadow there is nothing to stop
+func (engine *Engine) Close() {
+	if engine.shadow != nil {
+		engine.shadow.stop()
+	}
+}
+
+// History returns the history the engine was built with, nil unless built WithHistory
+func (engine *Engine) History() *History {
+	return engine.history
+}
+
+// Config returns the configuration the engine was built with
+func (engine *Engine) Config() Config {
+	return engine.config
+}
+
+// Version returns the version of the configuration, see Config.Version
+func (engine *Engine) Version() string {
+	return engine.version
+}
+
+// Assessment is the outcome of the rules for one transaction
+type Assessment struct {
+	// the transaction as given, with RiskRate set to its assessed risk
+	Transaction Transaction
+	// names of the rules that rated the transaction above low risk, in evaluation order
+	MatchedRules []string
+}
+
+// Assess returns the risk of each transaction, and the action it calls for, in the same order they were given,
+// stamped with the version of the rules. The slice received is not modified. It stops early, returning ctx.Err(),
+// if ctx is canceled
+func (engine *Engine) Assess(ctx context.Context, transactions []Transaction) (RiskRateResults, error) {
+	assessments, err := engine.Explain(ctx, transactions)
+	if err != nil {
+		return RiskRateResults{}, err
+	}
+	results := Results(assessments)
+	results.Actions = engine.Decide(assessments)
+	results.RuleSetVersion = engine.version
+	return results, nil
+}
+
+// Explain is like Assess, also telling which rules matched each transaction
+func (engine *Engine) Explain(ctx context.Context, transactions []Transaction) ([]Assessment, error) {
+	pending, err := engine.ExplainPending(ctx, transactions)
+	if err != nil {
+		return nil, err
+	}
+	pending.Commit(ctx)
+	return pending.Assessments, nil
+}
+
+// PendingAssessment is the outcome of ExplainPending, not remembered until committed
+type PendingAssessment struct {
+	Assessments []Assessment
+
+	engine       *Engine
+	batch        *Batch
+	transactions []Transaction
+}
+
+// ExplainPending is like Explain, but leaves the history unchanged until Commit is called, so a caller that fails
+// to keep the assessment, e.g. to record it, can drop it as if it was never made
+func (engine *Engine) ExplainPending(ctx context.Context, transactions []Transaction) (*PendingAssessment, error) {
+	for _, transaction := range transactions {
+		if err := transaction.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// RelateUserToTransactions numbers the lines in place, so it works over a copy
+	transactionsCopy := make([]Transaction, len(transactions))
+	copy(transactionsCopy, transactions)
+
+	batch := relateTransactions(transactionsCopy)
+	assessments, err := engine.checkTransactions(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	return &PendingAssessment{Assessments: assessments, engine: engine, batch: batch, transactions: transactions}, nil
+}
+
+// Commit remembers the batch in the engine history and compares it with the shadow rules. Only completed
+// assessments are remembered, and a pending one must be committed once at most
+func (pending *PendingAssessment) Commit(ctx context.Context) {
+	if pending.engine.history != nil {
+		pending.engine.history.record(pending.batch)
+	}
+	if pending.engine.shadow != nil {
+		pending.engine.shadow.submit(ctx, pending.transactions, Results(pending.Assessments))
+	}
+}
+
+// applies every rule to each group of transactions it asks for, returning their assessments ordered by line number
+func (engine *Engine) checkTransactions(ctx context.Context, batch *Batch) ([]Assessment, error) {
+	batch.History = engine.history
+	batch.FraudLabels = engine.fraudLabels
+
+	// every grouping is built once, the user one always since it holds every transaction
+	groupings := map[string][][]Transaction{ByUser.Name: batch.groups(ByUser)}
+	for _, rule := range engine.rules {
+		groupKey := rule.GroupBy.orByUser()
+		if _, isBuilt := groupings[groupKey.Name]; !isBuilt {
+			groupings[groupKey.Name] = batch.groups(groupKey)
+		}
+	}
+	for _, groups := range groupings {
+		for _, transactSlice := range groups {
+			// rules sum the amounts of the group, a batch whose sums overflow cannot be assessed
+			if _, err := runningTotals(transactSlice); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var assessments []Assessment
+	for _, transactSlice := range groupings[ByUser.Name] {
+		for _, transaction := range transactSlice {
+			assessments = append(assessments, Assessment{Transaction: transaction})
+		}
+	}
+	sort.Slice(assessments, func(i, j int) bool {
+		return assessments[i].Transaction.LineNumber < assessments[j].Transaction.LineNumber
+	})
+	// whatever group a rule received a transaction in, its result goes to the same assessment
+	assessmentIndexes := make(map[int]int, len(assessments))
+	for index, assessment := range assessments {
+		assessmentIndexes[assessment.Transaction.LineNumber] = index
+	}
+
+	// each rule runs over its own copy, starting from low risk, so what it matched is known.
+	// Since rules only raise risks, merging their results gives the same risk as chaining them
+	for _, rule := range engine.rules {
+		for _, transactSlice := range groupings[rule.GroupBy.orByUser().Name] {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			ruleSlice := make([]Transaction, len(transactSlice))
+			copy(ruleSlice, transactSlice)
+			for index := range ruleSlice {
+				ruleSlice[index].RiskRate = LOW
+			}
+			rule.Evaluate(ruleSlice, batch)
+
+			for _, transaction := range ruleSlice {
+				if transaction.RiskRate == LOW {
+					continue
+				}
+				assessment := &assessments[assessmentIndexes[transaction.LineNumber]]
+				assessment.Transaction.RiskRate = greaterRisk(assessment.Transaction.RiskRate, transaction.RiskRate)
+				assessment.MatchedRules = append(assessment.MatchedRules, rule.Name)
+			}
+		}
+	}
+	return assessments, nil
+}
+
+// Results returns the risks of the assessments, in the RiskRateResults format of Assess
+func Results(assessments []Assessment) RiskRateResults {
+	transactions := make([]Transaction, len(assessments))
+	for index, assessment := range assessments {
+		transactions[index] = assessment.Transaction
+	}
+	return allTransactionsRisk(transactions)
+}
@@ -0,0 +1,271 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineAssess(t *testing.T) {
+	tests := []struct {
+		name         string
+		options      []Option
+		transactions []Transaction
+		want         RiskRateResults
+	}{
+		{
+			name: "default rules should match the README example",
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, Amount: USD(200000), IdCardUsed: 1},
+				{TransactionId: 2, UserId: 1, Amount: USD(600000), IdCardUsed: 1},
+				{TransactionId: 3, UserId: 1, Amount: USD(1100000), IdCardUsed: 1},
+				{TransactionId: 4, UserId: 2, Amount: USD(100000), IdCardUsed: 2},
+				{TransactionId: 5, UserId: 2, Amount: USD(100000), IdCardUsed: 3},
+				{TransactionId: 6, UserId: 2, Amount: USD(100000), IdCardUsed: 4},
+			},
+			want: RiskRateResults{
+				RiskRates: []string{"low", "medium", "high", "low", "medium", "high"},
+				Actions:   []Action{Approve, HoldForReview, Decline, Approve, HoldForReview, Decline},
+			},
+		},
+		{
+			name: "custom rules should replace the default ones",
+			options: []Option{WithRules(Rule{
+				Name: "every_other_line",
+				Evaluate: func(userTransactions []Transaction, _ *Batch) {
+					for index := range userTransactions {
+						if userTransactions[index].LineNumber%2 == 0 {
+							userTransactions[index].RiskRate = greaterRisk(userTransactions[index].RiskRate, HIGH)
+						}
+					}
+				},
+			})},
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, Amount: USD(5000000), IdCardUsed: 1},
+				{TransactionId: 2, UserId: 2, Amount: USD(1), IdCardUsed: 1},
+			},
+			want: RiskRateResults{RiskRates: []string{"low", "high"}, Actions: []Action{Approve, Decline}},
+		},
+		{
+			name: "configured merchant rules should be evaluated alongside the amount and card rules",
+			options: []Option{WithConfig(Config{Merchant: MerchantConfig{
+				HighRiskCategories: []string{"6051"},
+				CategoryThresholds: map[string]AmountThresholds{"5812": {Medium: 10000, High: 20000}},
+			}})},
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, Amount: USD(100), IdCardUsed: 1, MerchantCategoryCode: "6051"},
+				{TransactionId: 2, UserId: 2, Amount: USD(15000), IdCardUsed: 2, MerchantCategoryCode: "5812"},
+				{TransactionId: 3, UserId: 2, Amount: USD(600000), IdCardUsed: 2, MerchantCategoryCode: "5411"},
+			},
+			want: RiskRateResults{
+				RiskRates: []string{"high", "medium", "medium"},
+				// no decision policy configured, the default one applies
+				Actions: []Action{Decline, ApproveWithChallenge, HoldForReview},
+			},
+		},
+		{
+			name:         "no transactions should return no ratings",
+			transactions: nil,
+			want:         RiskRateResults{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskEngine := New(test.options...)
+			got, err := riskEngine.Assess(context.Background(), test.transactions)
+			assert.NoError(t, err)
+			test.want.RuleSetVersion = riskEngine.Version()
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestEngineAssess_DoesNotModifyInput(t *testing.T) {
+	transactions := []Transaction{{TransactionId: 1, UserId: 1, Amount: USD(600000), IdCardUsed: 1}}
+
+	_, err := New().Assess(context.Background(), transactions)
+	assert.NoError(t, err)
+	assert.Equal(t, []Transaction{{TransactionId: 1, UserId: 1, Amount: USD(600000), IdCardUsed: 1}}, transactions)
+}
+
+func TestEngineAssess_CanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := New().Assess(ctx, []Transaction{{TransactionId: 1, UserId: 1}})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestEngineAssess_AmountOverflow(t *testing.T) {
+	riskEngine := New(WithHistory(NewHistory()))
+	transactions := []Transaction{{TransactionId: 1, UserId: 1, Amount: USD(math.MaxInt64)}, {TransactionId: 2, UserId: 1, Amount: USD(1)}}
+
+	_, err := riskEngine.Assess(context.Background(), transactions)
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+	// refused batches are not remembered
+	assert.Empty(t, riskEngine.History().UserAmounts(1))
+}
+
+func TestEngineAssess_InvalidTransaction(t *testing.T) {
+	_, err := New().Assess(context.Background(), []Transaction{{TransactionId: 1, UserId: 1}, {TransactionId: 2, UserId: 1, Type: Reversal}})
+	assert.ErrorIs(t, err, ErrInvalidTransaction)
+	assert.ErrorContains(t, err, "transaction 2: a reversal needs the reference_id")
+
+	_, err = New().Assess(context.Background(), []Transaction{{TransactionId: 3, UserId: 1, Type: "chargeback"}})
+	assert.ErrorIs(t, err, ErrInvalidTransaction)
+}
+
+// the default configuration with the card sharing rule enabled
+func cardSharingConfig() Config {
+	config := DefaultConfig()
+	config.CardSharing = UsersThresholds{MediumRiskUsers: 1, HighRiskUsers: 2}
+	return config
+}
+
+func TestEngineAssess_WithHistory(t *testing.T) {
+	riskEngine := New(WithConfig(cardSharingConfig()), WithHistory(NewHistory()))
+	ctx := context.Background()
+
+	// card 7 used by a single user, nothing shared yet
+	got, err := riskEngine.Assess(ctx, []Transaction{{TransactionId: 1, UserId: 1, IdCardUsed: 7}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, got.RiskRates)
+
+	// a second user in another request makes the card shared
+	got, err = riskEngine.Assess(ctx, []Transaction{{TransactionId: 2, UserId: 2, IdCardUsed: 7}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"medium"}, got.RiskRates)
+
+	// without history the engine only sees the current batch
+	got, err = New(WithConfig(cardSharingConfig())).Assess(ctx, []Transaction{{TransactionId: 2, UserId: 2, IdCardUsed: 7}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, got.RiskRates)
+}
+
+func TestEngineExplainPending(t *testing.T) {
+	history := NewHistory()
+	riskEngine := New(WithConfig(cardSharingConfig()), WithHistory(history))
+	ctx := context.Background()
+
+	// dropped, the card is not remembered
+	pending, err := riskEngine.ExplainPending(ctx, []Transaction{{TransactionId: 1, UserId: 1, IdCardUsed: 7}})
+	assert.NoError(t, err)
+	assert.Equal(t, LOW, pending.Assessments[0].Transaction.RiskRate)
+	assert.Empty(t, history.UserAmounts(1))
+	got, err := riskEngine.Assess(ctx, []Transaction{{TransactionId: 2, UserId: 2, IdCardUsed: 7}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, got.RiskRates)
+
+	// committed, the card is shared from now on
+	pending, err = riskEngine.ExplainPending(ctx, []Transaction{{TransactionId: 3, UserId: 3, IdCardUsed: 8}})
+	assert.NoError(t, err)
+	pending.Commit(ctx)
+	assert.Len(t, history.UserAmounts(3), 1)
+	got, err = riskEngine.Assess(ctx, []Transaction{{TransactionId: 4, UserId: 4, IdCardUsed: 8}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"medium"}, got.RiskRates)
+}
+
+func TestEngineReconfigure(t *testing.T) {
+	riskEngine := New(WithConfig(cardSharingConfig()), WithHistory(NewHistory()))
+	ctx := context.Background()
+	_, err := riskEngine.Assess(ctx, []Transaction{{TransactionId: 1, UserId: 1, IdCardUsed: 7}})
+	assert.NoError(t, err)
+
+	config := DefaultConfig()
+	reconfigured := riskEngine.Reconfigure(config)
+	assert.Equal(t, config.Version(), reconfigured.Version())
+	assert.Same(t, riskEngine.History(), reconfigured.History())
+
+	// the shared card is no longer a risk, but the history still knows its first user
+	got, err := reconfigured.Assess(ctx, []Transaction{{TransactionId: 2, UserId: 2, IdCardUsed: 7}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, got.RiskRates)
+	assert.Equal(t, config.Version(), got.RuleSetVersion)
+	got, err = riskEngine.Assess(ctx, []Transaction{{TransactionId: 3, UserId: 3, IdCardUsed: 7}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"high"}, got.RiskRates)
+	assert.Equal(t, cardSharingConfig().Version(), got.RuleSetVersion)
+}
+
+func TestEngineExplain(t *testing.T) {
+	assessments, err := New().Explain(context.Background(), []Transaction{
+		{TransactionId: 1, UserId: 1, Amount: USD(1100000), IdCardUsed: 1},
+		{TransactionId: 2, UserId: 2, Amount: USD(100), IdCardUsed: 2},
+		{TransactionId: 3, UserId: 1, Amount: USD(100), IdCardUsed: 3},
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, assessments, 3)
+	assert.Equal(t, HIGH, assessments[0].Transaction.RiskRate)
+	assert.Equal(t, []string{"single_amount", "total_amount"}, assessments[0].MatchedRules)
+	assert.Equal(t, LOW, assessments[1].Transaction.RiskRate)
+	assert.Empty(t, assessments[1].MatchedRules)
+	// input order is kept, with the rules of the later transaction of user 1
+	assert.Equal(t, uint(3), assessments[2].Transaction.TransactionId)
+	assert.Equal(t, MEDIUM, assessments[2].Transaction.RiskRate)
+	assert.Equal(t, []string{"total_amount", "multiple_cards"}, assessments[2].MatchedRules)
+}
+
+func TestEngineExplain_CardRules(t *testing.T) {
+	config := DefaultConfig()
+	config.Card = CardConfig{MediumTotalAmount: 50000, HighTotalAmount: 100000, MediumRiskMerchants: 2, HighRiskMerchants: 3}
+	// no shared_card, so only the card rules relate the users
+	config.CardSharing = UsersThresholds{}
+
+	assessments, err := New(WithConfig(config)).Explain(context.Background(), []Transaction{
+		{TransactionId: 1, UserId: 1, Amount: USD(40000), IdCardUsed: 1, MerchantId: 1},
+		{TransactionId: 2, UserId: 2, Amount: USD(40000), IdCardUsed: 1, MerchantId: 2},
+		{TransactionId: 3, UserId: 3, Amount: USD(100), IdCardUsed: 2, MerchantId: 3},
+		{TransactionId: 4, UserId: 3, Amount: USD(40000), IdCardUsed: 1, MerchantId: 3},
+	})
+	assert.NoError(t, err)
+
+	// each user spent little, the card as a whole did not
+	assert.Equal(t, []RiskLevel{LOW, MEDIUM, LOW, HIGH}, []RiskLevel{
+		assessments[0].Transaction.RiskRate, assessments[1].Transaction.RiskRate,
+		assessments[2].Transaction.RiskRate, assessments[3].Transaction.RiskRate,
+	})
+	assert.Empty(t, assessments[0].MatchedRules)
+	assert.Equal(t, []string{"card_total_amount"}, assessments[1].MatchedRules)
+	assert.Empty(t, assessments[2].MatchedRules)
+	// rules keep their evaluation order, whatever transactions they group
+	assert.Equal(t, []string{"multiple_cards", "card_total_amount", "card_merchants"}, assessments[3].MatchedRules)
+}
+
+func TestEngineAssess_CardAmountOverflow(t *testing.T) {
+	// each user's total fits, the card's does not
+	_, err := New().Assess(context.Background(), []Transaction{
+		{TransactionId: 1, UserId: 1, Amount: USD(math.MaxInt64), IdCardUsed: 1},
+		{TransactionId: 2, UserId: 2, Amount: USD(1), IdCardUsed: 1},
+	})
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+}
+
+func TestEngineExplain_GroupBy(t *testing.T) {
+	// flags every transaction after the first of its group
+	repeated := func(transactions []Transaction, _ *Batch) {
+		for index := 1; index < len(transactions); index++ {
+			transactions[index].RiskRate = MEDIUM
+		}
+	}
+	riskEngine := New(WithRules(
+		Rule{Name: "repeated_user_merchant", GroupBy: Composite(ByUser, ByMerchant), Evaluate: repeated},
+		Rule{Name: "repeated_device", GroupBy: ByDevice, Evaluate: repeated},
+	))
+
+	assessments, err := riskEngine.Explain(context.Background(), []Transaction{
+		{TransactionId: 1, UserId: 1, MerchantId: 1, DeviceId: "phone"},
+		{TransactionId: 2, UserId: 2, MerchantId: 1, DeviceId: "phone"},
+		{TransactionId: 3, UserId: 1, MerchantId: 1},
+	})
+	assert.NoError(t, err)
+
+	assert.Empty(t, assessments[0].MatchedRules)
+	assert.Equal(t, []string{"repeated_device"}, assessments[1].MatchedRules)
+	assert.Equal(t, []string{"repeated_user_merchant"}, assessments[2].MatchedRules)
+	assert.Equal(t, MEDIUM, assessments[2].Transaction.RiskRate)
+}
@@ -0,0 +1,505 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ExpressionError is a syntax or type error in an expression, positioned at the token that caused it
+type ExpressionError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (expressionError *ExpressionError) Error() string {
+	return fmt.Sprintf("line %d column %d: %s", expressionError.Line, expressionError.Column, expressionError.Message)
+}
+
+// types an expression can evaluate to
+type valueKind int
+
+const (
+	numberKind valueKind = iota
+	stringKind
+	boolKind
+	listKind
+)
+
+func (kind valueKind) String() string {
+	switch kind {
+	case numberKind:
+		return "number"
+	case stringKind:
+		return "string"
+	case boolKind:
+		return "bool"
+	case listKind:
+		return "list"
+	}
+	return "unknown"
+}
+
+// compiledExpression is a type checked expression, evaluated against the variables of one transaction.
+// Numbers are float64, strings string, bools bool and lists []any
+type compiledExpression struct {
+	kind valueKind
+	// kind of the items of a non empty list
+	itemKind valueKind
+	evaluate func(variables map[string]any) any
+}
+
+// compileExpression parses source, checking it only uses the given variables and evaluates to a bool
+func compileExpression(source string, variables map[string]valueKind) (compiledExpression, error) {
+	tokens, err := tokenizeExpression(source)
+	if err != nil {
+		return compiledExpression{}, err
+	}
+
+	parser := &expressionParser{tokens: tokens, variables: variables}
+	expression, err := parser.parseOr()
+	if err != nil {
+		return compiledExpression{}, err
+	}
+	if next := parser.peek(); next.kind != endToken {
+		return compiledExpression{}, next.errorf("unexpected %s", next)
+	}
+	if expression.kind != boolKind {
+		return compiledExpression{}, &ExpressionError{Line: 1, Column: 1, Message: fmt.Sprintf("expression must be a bool, not a %s", expression.kind)}
+	}
+	return expression, nil
+}
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	numberToken
+	stringToken
+	identifierToken
+	operatorToken
+)
+
+type expressionToken struct {
+	kind   tokenKind
+	text   string
+	line   int
+	column int
+}
+
+func (token expressionToken) String() string {
+	if token.kind == endToken {
+		return "end of expression"
+	}
+	return strconv.Quote(token.text)
+}
+
+func (token expressionToken) errorf(format string, arguments ...any) *ExpressionError {
+	return &ExpressionError{Line: token.line, Column: token.column, Message: fmt.Sprintf(format, arguments...)}
+}
+
+// operators made of two characters are matched before the single character ones
+var expressionOperators = []string{"==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "(", ")", "[", "]", ","}
+
+// splits the source in tokens, keeping the position of each one for error messages
+func tokenizeExpression(source string) ([]expressionToken, error) {
+	var tokens []expressionToken
+	runes := []rune(source)
+	line, column := 1, 1
+
+	for position := 0; position < len(runes); {
+		current := runes[position]
+		start := expressionToken{line: line, column: column}
+
+		switch {
+		case current == '\n':
+			line, column = line+1, 1
+			position++
+			continue
+		case unicode.IsSpace(current):
+			column++
+			position++
+			continue
+		case unicode.IsDigit(current):
+			end := position
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.' || runes[end] == '_') {
+				end++
+			}
+			start.kind, start.text = numberToken, string(runes[position:end])
+		case unicode.IsLetter(current) || current == '_':
+			end := position
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			start.kind, start.text = identifierToken, string(runes[position:end])
+		case current == '"':
+			end := position + 1
+			for end < len(runes) && runes[end] != '"' && runes[end] != '\n' {
+				end++
+			}
+			if end >= len(runes) || runes[end] != '"' {
+				return nil, start.errorf("unterminated string")
+			}
+			// the token text keeps the quotes so its length matches the source
+			start.kind, start.text = stringToken, string(runes[position:end+1])
+		default:
+			for _, operator := range expressionOperators {
+				if strings.HasPrefix(string(runes[position:]), operator) {
+					start.kind, start.text = operatorToken, operator
+					break
+				}
+			}
+			if start.kind != operatorToken {
+				return nil, start.errorf("unexpected character %q", current)
+			}
+		}
+
+		tokens = append(tokens, start)
+		length := len([]rune(start.text))
+		position += length
+		column += length
+	}
+	return append(tokens, expressionToken{kind: endToken, line: line, column: column}), nil
+}
+
+// recursive descent parser, from the lowest precedence (or) to the highest (literals)
+type expressionParser struct {
+	tokens    []expressionToken
+	position  int
+	variables map[string]valueKind
+}
+
+func (parser *expressionParser) peek() expressionToken {
+	return parser.tokens[parser.position]
+}
+
+func (parser *expressionParser) next() expressionToken {
+	token := parser.tokens[parser.position]
+	if token.kind != endToken {
+		parser.position++
+	}
+	return token
+}
+
+// consumes the token when it is the given keyword or operator
+func (parser *expressionParser) accept(text string) (expressionToken, bool) {
+	token := parser.peek()
+	if (token.kind == identifierToken || token.kind == operatorToken) && token.text == text {
+		return parser.next(), true
+	}
+	return token, false
+}
+
+func (parser *expressionParser) expect(text string) error {
+	if token, ok := parser.accept(text); !ok {
+		return token.errorf("expected %q, found %s", text, token)
+	}
+	return nil
+}
+
+// checks both sides of a logical operator are bools
+func logicalOperands(operator expressionToken, left, right compiledExpression) error {
+	if left.kind != boolKind || right.kind != boolKind {
+		return operator.errorf("%q needs bool operands, found %s and %s", operator.text, left.kind, right.kind)
+	}
+	return nil
+}
+
+func (parser *expressionParser) parseOr() (compiledExpression, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return left, err
+	}
+	for {
+		operator, ok := parser.accept("or")
+		if !ok {
+			return left, nil
+		}
+		right, err := parser.parseAnd()
+		if err != nil {
+			return right, err
+		}
+		if err := logicalOperands(operator, left, right); err != nil {
+			return left, err
+		}
+		leftEvaluate, rightEvaluate := left.evaluate, right.evaluate
+		left = compiledExpression{kind: boolKind, evaluate: func(variables map[string]any) any {
+			return leftEvaluate(variables).(bool) || rightEvaluate(variables).(bool)
+		}}
+	}
+}
+
+func (parser *expressionParser) parseAnd() (compiledExpression, error) {
+	left, err := parser.parseNot()
+	if err != nil {
+		return left, err
+	}
+	for {
+		operator, ok := parser.accept("and")
+		if !ok {
+			return left, nil
+		}
+		right, err := parser.parseNot()
+		if err != nil {
+			return right, err
+		}
+		if err := logicalOperands(operator, left, right); err != nil {
+			return left, err
+		}
+		leftEvaluate, rightEvaluate := left.evaluate, right.evaluate
+		left = compiledExpression{kind: boolKind, evaluate: func(variables map[string]any) any {
+			return leftEvaluate(variables).(bool) && rightEvaluate(variables).(bool)
+		}}
+	}
+}
+
+func (parser *expressionParser) parseNot() (compiledExpression, error) {
+	operator, ok := parser.accept("not")
+	if !ok {
+		return parser.parseComparison()
+	}
+	operand, err := parser.parseNot()
+	if err != nil {
+		return operand, err
+	}
+	if operand.kind != boolKind {
+		return operand, operator.errorf(`"not" needs a bool operand, found %s`, operand.kind)
+	}
+	return compiledExpression{kind: boolKind, evaluate: func(variables map[string]any) any {
+		return !operand.evaluate(variables).(bool)
+	}}, nil
+}
+
+func (parser *expressionParser) parseComparison() (compiledExpression, error) {
+	left, err := parser.parseAdditive()
+	if err != nil {
+		return left, err
+	}
+
+	operator := parser.peek()
+	isComparison := operator.kind == operatorToken && comparisonOperators[operator.text]
+	isMembership := operator.kind == identifierToken && operator.text == "in"
+	if !isComparison && !isMembership {
+		return left, nil
+	}
+	parser.next()
+
+	right, err := parser.parseAdditive()
+	if err != nil {
+		return right, err
+	}
+
+	if isMembership {
+		if right.kind != listKind {
+			return left, operator.errorf(`"in" needs a list on the right, found %s`, right.kind)
+		}
+		if isEmpty := len(right.evaluate(nil).([]any)) == 0; !isEmpty && left.kind != right.itemKind {
+			return left, operator.errorf("cannot look for a %s in a list of %s", left.kind, right.itemKind)
+		}
+		return compiledExpression{kind: boolKind, evaluate: func(variables map[string]any) any {
+			value := left.evaluate(variables)
+			for _, item := range right.evaluate(variables).([]any) {
+				if item == value {
+					return true
+				}
+			}
+			return false
+		}}, nil
+	}
+
+	if left.kind != right.kind || left.kind == listKind {
+		return left, operator.errorf("cannot compare %s with %s", left.kind, right.kind)
+	}
+	if left.kind == boolKind && operator.text != "==" && operator.text != "!=" {
+		return left, operator.errorf("bools can only be compared with == and !=")
+	}
+	return compiledExpression{kind: boolKind, evaluate: func(variables map[string]any) any {
+		return compareValues(operator.text, left.evaluate(variables), right.evaluate(variables))
+	}}, nil
+}
+
+var comparisonOperators = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// applies a comparison operator to two values of the same kind
+func compareValues(operator string, left, right any) bool {
+	switch operator {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+
+	var comparison int
+	switch left := left.(type) {
+	case float64:
+		right := right.(float64)
+		if left < right {
+			comparison = -1
+		} else if left > right {
+			comparison = 1
+		}
+	case string:
+		comparison = strings.Compare(left, right.(string))
+	}
+
+	switch operator {
+	case "<":
+		return comparison < 0
+	case "<=":
+		return comparison <= 0
+	case ">":
+		return comparison > 0
+	}
+	return comparison >= 0
+}
+
+// parses a chain of operators of the same precedence over numbers
+func (parser *expressionParser) parseArithmetic(operators string, parseOperand func() (compiledExpression, error)) (compiledExpression, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return left, err
+	}
+	for {
+		operator := parser.peek()
+		if operator.kind != operatorToken || !strings.Contains(operators, operator.text) {
+			return left, nil
+		}
+		parser.next()
+
+		right, err := parseOperand()
+		if err != nil {
+			return right, err
+		}
+		if left.kind != numberKind || right.kind != numberKind {
+			return left, operator.errorf("%q needs number operands, found %s and %s", operator.text, left.kind, right.kind)
+		}
+
+		leftEvaluate, rightEvaluate := left.evaluate, right.evaluate
+		left = compiledExpression{kind: numberKind, evaluate: func(variables map[string]any) any {
+			leftValue, rightValue := leftEvaluate(variables).(float64), rightEvaluate(variables).(float64)
+			switch operator.text {
+			case "+":
+				return leftValue + rightValue
+			case "-":
+				return leftValue - rightValue
+			case "*":
+				return leftValue * rightValue
+			}
+			return leftValue / rightValue
+		}}
+	}
+}
+
+func (parser *expressionParser) parseAdditive() (compiledExpression, error) {
+	return parser.parseArithmetic("+-", parser.parseMultiplicative)
+}
+
+func (parser *expressionParser) parseMultiplicative() (compiledExpression, error) {
+	return parser.parseArithmetic("*/", parser.parseUnary)
+}
+
+func (parser *expressionParser) parseUnary() (compiledExpression, error) {
+	operator, ok := parser.accept("-")
+	if !ok {
+		return parser.parsePrimary()
+	}
+	operand, err := parser.parseUnary()
+	if err != nil {
+		return operand, err
+	}
+	if operand.kind != numberKind {
+		return operand, operator.errorf(`"-" needs a number operand, found %s`, operand.kind)
+	}
+	return compiledExpression{kind: numberKind, evaluate: func(variables map[string]any) any {
+		return -operand.evaluate(variables).(float64)
+	}}, nil
+}
+
+// literals, variables, lists and parenthesized expressions
+func (parser *expressionParser) parsePrimary() (compiledExpression, error) {
+	token := parser.next()
+
+	switch token.kind {
+	case numberToken:
+		number, err := strconv.ParseFloat(strings.ReplaceAll(token.text, "_", ""), 64)
+		if err != nil {
+			return compiledExpression{}, token.errorf("invalid number %s", token)
+		}
+		return constantExpression(numberKind, number), nil
+	case stringToken:
+		return constantExpression(stringKind, strings.Trim(token.text, `"`)), nil
+	case identifierToken:
+		switch token.text {
+		case "true", "false":
+			return constantExpression(boolKind, token.text == "true"), nil
+		case "and", "or", "not", "in":
+			return compiledExpression{}, token.errorf("unexpected %s", token)
+		}
+		kind, isKnownVariable := parser.variables[token.text]
+		if !isKnownVariable {
+			return compiledExpression{}, token.errorf("unknown variable %s", token)
+		}
+		name := token.text
+		return compiledExpression{kind: kind, evaluate: func(variables map[string]any) any { return variables[name] }}, nil
+	case operatorToken:
+		switch token.text {
+		case "(":
+			expression, err := parser.parseOr()
+			if err != nil {
+				return expression, err
+			}
+			return expression, parser.expect(")")
+		case "[":
+			return parser.parseList(token)
+		}
+	}
+	return compiledExpression{}, token.errorf("unexpected %s", token)
+}
+
+// list of constant numbers or strings, e.g. ["7995", "6051"]
+func (parser *expressionParser) parseList(opening expressionToken) (compiledExpression, error) {
+	var items []any
+	var itemKind valueKind
+
+	for {
+		if _, ok := parser.accept("]"); ok {
+			list := constantExpression(listKind, items)
+			list.itemKind = itemKind
+			return list, nil
+		}
+		if len(items) > 0 {
+			if err := parser.expect(","); err != nil {
+				return compiledExpression{}, err
+			}
+		}
+
+		token := parser.peek()
+		// items are evaluated once, here, so they must be literals. A negative number is two tokens
+		literal, isNegative := token, token.kind == operatorToken && token.text == "-"
+		if isNegative {
+			literal = parser.tokens[parser.position+1]
+		}
+		if literal.kind != numberToken && (isNegative || literal.kind != stringToken) {
+			return compiledExpression{}, literal.errorf("lists can only hold number or string literals, found %s", literal)
+		}
+		item, err := parser.parseUnary()
+		if err != nil {
+			return item, err
+		}
+		if len(items) > 0 && item.kind != itemKind {
+			return item, token.errorf("list mixes %s and %s", itemKind, item.kind)
+		}
+		itemKind = item.kind
+		items = append(items, item.evaluate(nil))
+
+		if parser.peek().kind == endToken {
+			return compiledExpression{}, opening.errorf("unclosed list")
+		}
+	}
+}
+
+func constantExpression(kind valueKind, value any) compiledExpression {
+	return compiledExpression{kind: kind, evaluate: func(map[string]any) any { return value }}
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileExpression(t *testing.T) {
+	variables := map[string]any{"amount": 350000.0, "mcc": "7995", "first": true}
+	kinds := map[string]valueKind{"amount": numberKind, "mcc": stringKind, "first": boolKind}
+
+	tests := []struct {
+		name   string
+		source string
+		want   bool
+	}{
+		{name: "number comparison", source: "amount > 300000", want: true},
+		{name: "underscores in numbers", source: "amount >= 350_000", want: true},
+		{name: "arithmetic precedence", source: "amount == 50000 + 100000 * 3", want: true},
+		{name: "unary minus and parentheses", source: "-(amount - 400000) == 50000", want: true},
+		{name: "string equality", source: `mcc != "5411"`, want: true},
+		{name: "list membership", source: `mcc in ["6051", "7995"]`, want: true},
+		{name: "number list membership", source: "amount in [1, 2]", want: false},
+		{name: "negative numbers in lists", source: "-amount in [-1, -350000]", want: true},
+		{name: "and binds tighter than or", source: "false and false or first", want: true},
+		{name: "not", source: "not first", want: false},
+		{name: "several lines", source: "amount > 300000\nand first", want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expression, err := compileExpression(test.source, kinds)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, expression.evaluate(variables))
+		})
+	}
+}
+
+func TestCompileExpression_Errors(t *testing.T) {
+	kinds := map[string]valueKind{"amount": numberKind, "mcc": stringKind}
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{name: "unknown variable", source: "amount > 1 and\nsize > 2", want: `line 2 column 1: unknown variable "size"`},
+		{name: "type mismatch", source: `amount == "100"`, want: `line 1 column 8: cannot compare number with string`},
+		{name: "not a bool", source: "amount + 1", want: "line 1 column 1: expression must be a bool, not a number"},
+		{name: "logical operator over numbers", source: "amount and amount", want: `line 1 column 8: "and" needs bool operands, found number and number`},
+		{name: "unterminated string", source: `mcc == "79`, want: "line 1 column 8: unterminated string"},
+		{name: "unexpected character", source: "amount > 1 & amount < 2", want: `line 1 column 12: unexpected character '&'`},
+		{name: "missing parenthesis", source: "(amount > 1", want: `line 1 column 12: expected ")", found end of expression`},
+		{name: "trailing tokens", source: "amount > 1 1", want: `line 1 column 12: unexpected "1"`},
+		{name: "list of another type", source: `mcc in [7995, 6051]`, want: "line 1 column 5: cannot look for a string in a list of number"},
+		{name: "variable in a list", source: `mcc in ["1", country]`, want: `line 1 column 14: lists can only hold number or string literals, found "country"`},
+		{name: "expression in a list", source: `amount in [1 + 2]`, want: `line 1 column 14: expected ",", found "+"`},
+		{name: "negated variable in a list", source: `amount in [-amount]`, want: `line 1 column 13: lists can only hold number or string literals, found "amount"`},
+		{name: "mixed list", source: `mcc in ["1", 2]`, want: "line 1 column 14: list mixes string and number"},
+		{name: "empty", source: "", want: "line 1 column 1: unexpected end of expression"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := compileExpression(test.source, kinds)
+			var expressionError *ExpressionError
+			assert.ErrorAs(t, err, &expressionError)
+			assert.EqualError(t, err, test.want)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// ExpressionSource is the text of an expression. In JSON it is either a string or a list of lines,
+// which is easier to read for long expressions and keeps error lines meaningful
+type ExpressionSource string
+
+func (source *ExpressionSource) UnmarshalJSON(content []byte) error {
+	var lines []string
+	if err := json.Unmarshal(content, &lines); err == nil {
+		*source = ExpressionSource(strings.Join(lines, "\n"))
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(content, &text); err != nil {
+		return fmt.Errorf("expression must be a string or a list of lines")
+	}
+	*source = ExpressionSource(text)
+	return nil
+}
+
+// ExpressionRule is a rule written in configuration: transactions for which When is true get the Risk level
+type ExpressionRule struct {
+	Name string           `json:"name"`
+	When ExpressionSource `json:"when"`
+	// "medium" or "high"
+	Risk string `json:"risk"`
+}
+
+// variables expressions can use, with their kind. Aggregates are over the user's transactions in the batch,
+// up to and including the evaluated one
+var expressionVariables = map[string]valueKind{
+	"id":              numberKind,
+	"user_id":         numberKind,
+	"amount_us_cents": numberKind,
+	"card_id":         numberKind,
+	"merchant_id":     numberKind,
+	"mcc":             stringKind,
+	"country":         stringKind,
+	"card_country":    stringKind,
+	"device_id":       stringKind,
+	"ip_address":      stringKind,
+	"user_agent":      stringKind,
+	"type":            stringKind,
+	// sum of the amounts
+	"running_total": numberKind,
+	// distinct cards used
+	"distinct_cards": numberKind,
+	// number of transactions, 1 for the user's first one
+	"tx_count": numberKind,
+	// users seen with the card, in the batch and in the history
+	"card_users": numberKind,
+	// first time the user uses the card in the batch
+	"card_first_use": boolKind,
+	// first transaction of the user with a known merchant in the batch
+	"new_merchant": boolKind,
+}
+
+// compiles the rule expression and parses its risk, errors name the rule
+func (expressionRule ExpressionRule) compile() (compiledExpression, RiskLevel, error) {
+	if expressionRule.Name == "" {
+		return compiledExpression{}, LOW, fmt.Errorf("expression rule without a name")
+	}
+	risk, err := ParseRiskLevel(expressionRule.Risk)
+	if err != nil || risk == LOW {
+		return compiledExpression{}, LOW, fmt.Errorf("rule %s: risk must be medium or high, found %q", expressionRule.Name, expressionRule.Risk)
+	}
+	expression, err := compileExpression(string(expressionRule.When), expressionVariables)
+	if err != nil {
+		return compiledExpression{}, LOW, fmt.Errorf("rule %s: %w", expressionRule.Name, err)
+	}
+	return expression, risk, nil
+}
+
+// computes the variables of each transaction, in the user's order
+func expressionVariableValues(userTransactions []Transaction, batch *Batch) []map[string]any {
+	totals, err := runningTotals(userTransactions)
+	if err != nil {
+		// the engine refuses these batches before any rule runs
+		return nil
+	}
+	values := make([]map[string]any, len(userTransactions))
+	cardIdSet := mapset.NewSet[uint]()
+	merchantIdSet := mapset.NewSet[uint]()
+
+	for transacIndex, transaction := range userTransactions {
+		// Add returns false when the card or merchant was already in the set
+		cardFirstUse := cardIdSet.Add(transaction.IdCardUsed)
+		newMerchant := transaction.MerchantId != 0 && merchantIdSet.Add(transaction.MerchantId)
+
+		values[transacIndex] = map[string]any{
+			"id":              float64(transaction.TransactionId),
+			"user_id":         float64(transaction.UserId),
+			"amount_us_cents": float64(transaction.Amount.MinorUnits),
+			"card_id":         float64(transaction.IdCardUsed),
+			"merchant_id":     float64(transaction.MerchantId),
+			"mcc":             transaction.MerchantCategoryCode,
+			"country":         transaction.Country,
+			"card_country":    transaction.CardCountry,
+			"device_id":       transaction.DeviceId,
+			"ip_address":      transaction.IPAddress,
+			"user_agent":      transaction.UserAgent,
+			"type":            string(transaction.Type.OrPurchase()),
+			"running_total":   float64(totals[transacIndex].MinorUnits),
+			"distinct_cards":  float64(cardIdSet.Cardinality()),
+			"tx_count":        float64(transacIndex + 1),
+			"card_users":      float64(batch.cardUsers(transaction.IdCardUsed).Cardinality()),
+			"card_first_use":  cardFirstUse,
+			"new_merchant":    newMerchant,
+		}
+	}
+	return values
+}
+
+// Raises to risk the Risk Level of the transactions matching the expression
+func riskPerExpression(userTransactions []Transaction, batch *Batch, expression compiledExpression, risk RiskLevel) {
+	for transacIndex, variables := range expressionVariableValues(userTransactions, batch) {
+		if expression.evaluate(variables).(bool) {
+			currentTransaction := &userTransactions[transacIndex]
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+		}
+	}
+}
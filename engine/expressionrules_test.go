@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskPerExpression(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         ExpressionRule
+		transactions []Transaction
+		want         []RiskLevel
+	}{
+		{
+			name: "large amount on a card used for the first time should match",
+			rule: ExpressionRule{Name: "new_card_amount", When: "amount_us_cents > 300000 and card_first_use", Risk: "high"},
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, Amount: USD(400000), IdCardUsed: 1},
+				{TransactionId: 2, UserId: 1, Amount: USD(400000), IdCardUsed: 1},
+				{TransactionId: 3, UserId: 1, Amount: USD(100), IdCardUsed: 2},
+			},
+			want: []RiskLevel{HIGH, LOW, LOW},
+		},
+		{
+			name: "aggregates should count up to the evaluated transaction",
+			rule: ExpressionRule{Name: "busy_user", When: "tx_count >= 2 and running_total > 1000 or distinct_cards > 2", Risk: "medium"},
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, Amount: USD(600), IdCardUsed: 1},
+				{TransactionId: 2, UserId: 1, Amount: USD(600), IdCardUsed: 2},
+				{TransactionId: 3, UserId: 1, Amount: USD(0), IdCardUsed: 3},
+			},
+			want: []RiskLevel{LOW, MEDIUM, MEDIUM},
+		},
+		{
+			name: "type should default to purchase",
+			rule: ExpressionRule{Name: "cash", When: `type == "cash_withdrawal" and amount_us_cents > 50000 or type == "purchase"`, Risk: "medium"},
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, Amount: USD(60000), Type: CashWithdrawal},
+				{TransactionId: 2, UserId: 1, Amount: USD(40000), Type: CashWithdrawal},
+				{TransactionId: 3, UserId: 1, Amount: USD(100)},
+				{TransactionId: 4, UserId: 1, Amount: USD(100), Type: Transfer},
+			},
+			want: []RiskLevel{MEDIUM, LOW, MEDIUM, LOW},
+		},
+		{
+			name: "string fields should be comparable",
+			rule: ExpressionRule{Name: "gambling_abroad", When: `mcc in ["7995"] and country != card_country`, Risk: "high"},
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, MerchantCategoryCode: "7995", Country: "BR", CardCountry: "US"},
+				{TransactionId: 2, UserId: 1, MerchantCategoryCode: "7995", Country: "US", CardCountry: "US"},
+			},
+			want: []RiskLevel{HIGH, LOW},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expression, risk, err := test.rule.compile()
+			assert.NoError(t, err)
+			riskPerExpression(test.transactions, batchFromUsers(RelateUserToTransactions(test.transactions)), expression, risk)
+			assert.Equal(t, test.want, riskLevels(test.transactions))
+		})
+	}
+}
+
+func TestExpressionRuleCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule ExpressionRule
+		want string
+	}{
+		{name: "syntax error should name the rule and line", rule: ExpressionRule{Name: "broken", When: "amount_us_cents > 1 and\n(", Risk: "high"}, want: "rule broken: line 2 column 2: unexpected end of expression"},
+		{name: "low risk is pointless", rule: ExpressionRule{Name: "low", When: "true", Risk: "low"}, want: `rule low: risk must be medium or high, found "low"`},
+		{name: "name is required", rule: ExpressionRule{When: "true", Risk: "high"}, want: "expression rule without a name"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := test.rule.compile()
+			assert.EqualError(t, err, test.want)
+		})
+	}
+}
+
+func TestExpressionSource_UnmarshalJSON(t *testing.T) {
+	var rules []ExpressionRule
+	err := json.Unmarshal([]byte(`[{"name": "a", "when": "true"}, {"name": "b", "when": ["true", "and false"]}]`), &rules)
+	assert.NoError(t, err)
+	assert.Equal(t, ExpressionSource("true"), rules[0].When)
+	assert.Equal(t, ExpressionSource("true\nand false"), rules[1].When)
+
+	assert.Error(t, json.Unmarshal([]byte(`[{"when": 1}]`), &rules))
+}
@@ -0,0 +1,37 @@
+package engine
+
+import (
+	. "transactionriskassessment/domain"
+)
+
+// FraudLabels tells which cards and users analysts confirmed as involved in fraud
+type FraudLabels interface {
+	IsFraudCard(cardId uint) bool
+	IsFraudUser(userId uint) bool
+}
+
+// WithFraudLabels makes the confirmed_fraud rule raise the risk of cards and users with confirmed fraud
+func WithFraudLabels(labels FraudLabels) Option {
+	return func(engine *Engine) {
+		engine.fraudLabels = labels
+	}
+}
+
+// Raises the risk of transactions made with a card, or by a user, that analysts confirmed as involved in fraud
+func riskPerConfirmedFraud(userTransactions []Transaction, batch *Batch, cardRisk, userRisk RiskLevel) {
+	if batch.FraudLabels == nil || len(userTransactions) == 0 {
+		return
+	}
+	// every transaction belongs to the same user
+	isFraudUser := batch.FraudLabels.IsFraudUser(userTransactions[0].UserId)
+
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		if isFraudUser {
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, userRisk)
+		}
+		if batch.FraudLabels.IsFraudCard(currentTransaction.IdCardUsed) {
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, cardRisk)
+		}
+	}
+}
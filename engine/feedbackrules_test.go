@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixed fraud labels for tests
+type testFraudLabels struct {
+	cards map[uint]bool
+	users map[uint]bool
+}
+
+func (labels testFraudLabels) IsFraudCard(cardId uint) bool { return labels.cards[cardId] }
+func (labels testFraudLabels) IsFraudUser(userId uint) bool { return labels.users[userId] }
+
+func TestRiskPerConfirmedFraud(t *testing.T) {
+	labels := testFraudLabels{cards: map[uint]bool{3: true}, users: map[uint]bool{2: true}}
+
+	tests := []struct {
+		name         string
+		labels       FraudLabels
+		transactions []Transaction
+		want         []RiskLevel
+	}{
+		{
+			name:   "fraud card should raise its transactions only",
+			labels: labels,
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 1, IdCardUsed: 3},
+				{TransactionId: 2, UserId: 1, IdCardUsed: 4},
+			},
+			want: []RiskLevel{HIGH, LOW},
+		},
+		{
+			name:   "fraud user should raise all their transactions",
+			labels: labels,
+			transactions: []Transaction{
+				{TransactionId: 1, UserId: 2, IdCardUsed: 4},
+				{TransactionId: 2, UserId: 2, IdCardUsed: 3},
+			},
+			want: []RiskLevel{MEDIUM, HIGH},
+		},
+		{
+			name:         "no labels should change nothing",
+			transactions: []Transaction{{TransactionId: 1, UserId: 2, IdCardUsed: 3}},
+			want:         []RiskLevel{LOW},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerConfirmedFraud(test.transactions, &Batch{FraudLabels: test.labels}, HIGH, MEDIUM)
+			assert.Equal(t, test.want, riskLevels(test.transactions))
+		})
+	}
+}
+
+func TestEngineAssess_WithFraudLabels(t *testing.T) {
+	riskEngine := New(WithFraudLabels(testFraudLabels{cards: map[uint]bool{3: true}}))
+
+	got, err := riskEngine.Assess(context.Background(), []Transaction{{TransactionId: 1, UserId: 1, IdCardUsed: 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"high"}, got.RiskRates)
+
+	// "low" disables the rule
+	config := DefaultConfig()
+	config.Feedback.FraudCardRisk = "low"
+	got, err = New(WithConfig(config), WithFraudLabels(testFraudLabels{cards: map[uint]bool{3: true}})).
+		Assess(context.Background(), []Transaction{{TransactionId: 1, UserId: 1, IdCardUsed: 3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, got.RiskRates)
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	. "transactionriskassessment/domain"
+)
+
+// offline table with the approximate center of each country, keyed by ISO 3166-1 alpha-2 code
+//
+//go:embed countries.csv
+var countriesCSV string
+
+// latitude and longitude, in degrees
+type coordinates struct {
+	latitude  float64
+	longitude float64
+}
+
+// parsed once, the table is shipped with the binary so a malformed one is a programming error
+var countryCenters = mustParseCountries(countriesCSV)
+
+// mean Earth radius used by the haversine formula
+const earthRadiusKm = 6371.0
+
+// reads the "code,latitude,longitude,name" rows of the countries table
+func parseCountries(content string) (map[string]coordinates, error) {
+	rows, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	centers := make(map[string]coordinates, len(rows))
+	// first row is the header
+	for _, row := range rows[1:] {
+		latitude, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("country %s: %w", row[0], err)
+		}
+		longitude, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("country %s: %w", row[0], err)
+		}
+		centers[row[0]] = coordinates{latitude: latitude, longitude: longitude}
+	}
+	return centers, nil
+}
+
+func mustParseCountries(content string) map[string]coordinates {
+	centers, err := parseCountries(content)
+	if err != nil {
+		panic(err)
+	}
+	return centers
+}
+
+// returns where the transaction happened: its own coordinates or, when unset, the center of its country
+func transactionLocation(transaction Transaction) (coordinates, bool) {
+	if transaction.Latitude != nil && transaction.Longitude != nil {
+		return coordinates{latitude: *transaction.Latitude, longitude: *transaction.Longitude}, true
+	}
+	center, isKnownCountry := countryCenters[strings.ToUpper(transaction.Country)]
+	return center, isKnownCountry
+}
+
+// great-circle distance between two points, in kilometers
+func haversineKm(from, to coordinates) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	deltaLatitude := toRadians(to.latitude - from.latitude)
+	deltaLongitude := toRadians(to.longitude - from.longitude)
+	a := math.Sin(deltaLatitude/2)*math.Sin(deltaLatitude/2) +
+		math.Cos(toRadians(from.latitude))*math.Cos(toRadians(to.latitude))*math.Sin(deltaLongitude/2)*math.Sin(deltaLongitude/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// Raises to medium the risk of transactions made in a country other than the one that issued the card.
+// Transactions missing either country are skipped
+func riskPerCountryMismatch(userTransactions []Transaction) {
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		if currentTransaction.Country == "" || currentTransaction.CardCountry == "" {
+			continue
+		}
+		if !strings.EqualFold(currentTransaction.Country, currentTransaction.CardCountry) {
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, MEDIUM)
+		}
+	}
+}
+
+// Compares each located transaction with the previous one of the user, in time order, raising to high
+// the risk of the later one when going from one place to the other would need more than maxSpeedKmh.
+// Places closer than minDistanceKm are ignored, since locations are approximate
+func riskPerImpossibleTravel(userTransactions []Transaction, maxSpeedKmh, minDistanceKm float64) {
+	// zero disables the rule
+	if maxSpeedKmh <= 0 {
+		return
+	}
+
+	// positions of the transactions that can be placed in time and space
+	var locatedIndexes []int
+	for transacIndex, transaction := range userTransactions {
+		if _, isLocated := transactionLocation(transaction); isLocated && transaction.Timestamp != nil {
+			locatedIndexes = append(locatedIndexes, transacIndex)
+		}
+	}
+	// stable so transactions at the same moment keep the input order
+	sort.SliceStable(locatedIndexes, func(i, j int) bool {
+		return userTransactions[locatedIndexes[i]].Timestamp.Before(*userTransactions[locatedIndexes[j]].Timestamp)
+	})
+
+	for position := 1; position < len(locatedIndexes); position++ {
+		previous := userTransactions[locatedIndexes[position-1]]
+		currentTransaction := &userTransactions[locatedIndexes[position]]
+
+		from, _ := transactionLocation(previous)
+		to, _ := transactionLocation(*currentTransaction)
+		distance := haversineKm(from, to)
+		if distance < minDistanceKm {
+			continue
+		}
+
+		hours := currentTransaction.Timestamp.Sub(*previous.Timestamp).Hours()
+		// being in two far apart places at the same moment is as impossible as it gets
+		if hours <= 0 || distance/hours > maxSpeedKmh {
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, HIGH)
+		}
+	}
+}
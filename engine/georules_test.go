@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"testing"
+	"time"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// helpers to fill the optional pointer fields
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func timePointer(minutes int) *time.Time {
+	moment := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC).Add(time.Duration(minutes) * time.Minute)
+	return &moment
+}
+
+func TestCountriesTable(t *testing.T) {
+	centers, err := parseCountries(countriesCSV)
+	assert.NoError(t, err)
+	assert.Greater(t, len(centers), 200)
+	assert.Contains(t, centers, "BR")
+	assert.Contains(t, centers, "DE")
+
+	_, err = parseCountries("code,latitude,longitude,name\nBR,north,-51.9,Brazil\n")
+	assert.Error(t, err)
+}
+
+func TestHaversineKm(t *testing.T) {
+	saoPaulo := coordinates{latitude: -23.55, longitude: -46.63}
+	berlin := coordinates{latitude: 52.52, longitude: 13.405}
+
+	// around 10,250 km apart
+	assert.InDelta(t, 10250, haversineKm(saoPaulo, berlin), 50)
+	assert.Equal(t, 0.0, haversineKm(berlin, berlin))
+}
+
+func TestRiskPerCountryMismatch(t *testing.T) {
+	args := []Transaction{
+		{Country: "BR", CardCountry: "BR"},
+		{Country: "de", CardCountry: "BR"},
+		{Country: "DE", CardCountry: "de"},
+		{Country: "DE"},
+	}
+	want := []Transaction{
+		{Country: "BR", CardCountry: "BR", RiskRate: LOW},
+		{Country: "de", CardCountry: "BR", RiskRate: MEDIUM},
+		{Country: "DE", CardCountry: "de", RiskRate: LOW},
+		{Country: "DE", RiskRate: LOW},
+	}
+
+	riskPerCountryMismatch(args)
+	assert.Equal(t, want, args)
+}
+
+func TestRiskPerImpossibleTravel(t *testing.T) {
+	saoPauloLatitude, saoPauloLongitude := floatPointer(-23.55), floatPointer(-46.63)
+	berlinLatitude, berlinLongitude := floatPointer(52.52), floatPointer(13.405)
+
+	tests := []struct {
+		name string
+		args []Transaction
+		want []RiskLevel
+	}{
+		{
+			name: "São Paulo and Berlin twenty minutes apart should return high for the later one",
+			args: []Transaction{
+				{Latitude: saoPauloLatitude, Longitude: saoPauloLongitude, Timestamp: timePointer(0)},
+				{Latitude: berlinLatitude, Longitude: berlinLongitude, Timestamp: timePointer(20)},
+			},
+			want: []RiskLevel{LOW, HIGH},
+		},
+		{
+			name: "São Paulo and Berlin a day apart should return low",
+			args: []Transaction{
+				{Latitude: saoPauloLatitude, Longitude: saoPauloLongitude, Timestamp: timePointer(0)},
+				{Latitude: berlinLatitude, Longitude: berlinLongitude, Timestamp: timePointer(24 * 60)},
+			},
+			want: []RiskLevel{LOW, LOW},
+		},
+		{
+			name: "countries should be used when coordinates are missing, in time order instead of input order",
+			args: []Transaction{
+				{Country: "DE", Timestamp: timePointer(30)},
+				{Country: "BR", Timestamp: timePointer(0)},
+			},
+			want: []RiskLevel{HIGH, LOW},
+		},
+		{
+			name: "transactions without timestamp or location should be skipped",
+			args: []Transaction{
+				{Country: "BR", Timestamp: timePointer(0)},
+				{Country: "DE"},
+				{Timestamp: timePointer(1)},
+				{Country: "XX", Timestamp: timePointer(2)},
+			},
+			want: []RiskLevel{LOW, LOW, LOW, LOW},
+		},
+		{
+			name: "nearby places at the same moment should return low",
+			args: []Transaction{
+				{Latitude: floatPointer(-23.55), Longitude: floatPointer(-46.63), Timestamp: timePointer(0)},
+				{Latitude: floatPointer(-23.60), Longitude: floatPointer(-46.70), Timestamp: timePointer(0)},
+			},
+			want: []RiskLevel{LOW, LOW},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerImpossibleTravel(test.args, 1000, 100)
+			assert.Equal(t, test.want, riskLevels(test.args))
+		})
+	}
+}
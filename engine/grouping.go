@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	. "transactionriskassessment/domain"
+)
+
+// GroupKey tells which transactions of the batch a rule receives together: those sharing the same key
+type GroupKey struct {
+	// identifies the grouping, rules with the same name share the index built for the batch
+	Name string
+	// returns the group of the transaction, which must be comparable. False leaves the transaction out of every group
+	Key func(transaction Transaction) (any, bool)
+}
+
+var (
+	// the transactions of each user, received by rules that set no GroupKey
+	ByUser = GroupKey{Name: "user", Key: func(transaction Transaction) (any, bool) {
+		return transaction.UserId, true
+	}}
+	// the transactions made with each card, whoever made them
+	ByCard = GroupKey{Name: "card", Key: func(transaction Transaction) (any, bool) {
+		return transaction.IdCardUsed, true
+	}}
+	// the transactions coming from each device, see deviceFingerprint. Those without device are left out
+	ByDevice = GroupKey{Name: "device", Key: func(transaction Transaction) (any, bool) {
+		fingerprint := deviceFingerprint(transaction)
+		return fingerprint, fingerprint != ""
+	}}
+	// the transactions coming from each IP address. Those without IP address are left out
+	ByIP = GroupKey{Name: "ip", Key: func(transaction Transaction) (any, bool) {
+		return transaction.IPAddress, transaction.IPAddress != ""
+	}}
+	// the transactions made with each merchant. Those without merchant are left out
+	ByMerchant = GroupKey{Name: "merchant", Key: func(transaction Transaction) (any, bool) {
+		return transaction.MerchantId, transaction.MerchantId != 0
+	}}
+)
+
+// Composite groups the transactions sharing every one of the keys, e.g. Composite(ByUser, ByMerchant) gives each
+// user's transactions with each merchant. A transaction left out by any of the keys is left out
+func Composite(keys ...GroupKey) GroupKey {
+	names := make([]string, len(keys))
+	for index, key := range keys {
+		names[index] = key.Name
+	}
+	return GroupKey{Name: strings.Join(names, "+"), Key: func(transaction Transaction) (any, bool) {
+		values := make([]any, len(keys))
+		for index, key := range keys {
+			value, isGrouped := key.Key(transaction)
+			if !isGrouped {
+				return nil, false
+			}
+			values[index] = value
+		}
+		// slices are not comparable, their Go syntax representation is and tells "1" from 1
+		return fmt.Sprintf("%#v", values), true
+	}}
+}
+
+// returns the key, ByUser when it is not set
+func (key GroupKey) orByUser() GroupKey {
+	if key.Key == nil {
+		return ByUser
+	}
+	return key
+}
+
+// returns the transactions of the batch grouped by the key, every group ordered by input position
+func (batch *Batch) groups(key GroupKey) [][]Transaction {
+	groupIndexes := make(map[any]int)
+	var groups [][]Transaction
+	// every transaction of the batch is in one of the user sets
+	for _, transactions := range batch.Users {
+		for transaction := range transactions.Iter() {
+			value, isGrouped := key.Key(transaction)
+			if !isGrouped {
+				continue
+			}
+			groupIndex, isKnownGroup := groupIndexes[value]
+			if !isKnownGroup {
+				groupIndex = len(groups)
+				groupIndexes[value] = groupIndex
+				groups = append(groups, nil)
+			}
+			groups[groupIndex] = append(groups[groupIndex], transaction)
+		}
+	}
+
+	for _, group := range groups {
+		sort.Sort(TransactionsByPosition(group))
+	}
+	return groups
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"sort"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// returns the transaction ids of each group, groups sorted by their first line number
+func groupIds(groups [][]Transaction) [][]uint {
+	sortedGroups := make([][]Transaction, len(groups))
+	copy(sortedGroups, groups)
+	sort.Slice(sortedGroups, func(i, j int) bool {
+		return sortedGroups[i][0].LineNumber < sortedGroups[j][0].LineNumber
+	})
+
+	ids := make([][]uint, len(sortedGroups))
+	for index, group := range sortedGroups {
+		for _, transaction := range group {
+			ids[index] = append(ids[index], transaction.TransactionId)
+		}
+	}
+	return ids
+}
+
+func TestBatchGroups(t *testing.T) {
+	batch := relateTransactions([]Transaction{
+		{TransactionId: 1, UserId: 1, IdCardUsed: 1, MerchantId: 1, DeviceId: "phone", IPAddress: "203.0.113.7"},
+		{TransactionId: 2, UserId: 2, IdCardUsed: 1, MerchantId: 2, UserAgent: "Mozilla/5.0"},
+		{TransactionId: 3, UserId: 1, IdCardUsed: 2, MerchantId: 1, DeviceId: "phone", IPAddress: "203.0.113.7"},
+		{TransactionId: 4, UserId: 2, IdCardUsed: 2},
+		{TransactionId: 5, UserId: 1, IdCardUsed: 1, MerchantId: 2, IPAddress: "203.0.113.8"},
+	})
+
+	tests := []struct {
+		name string
+		key  GroupKey
+		want [][]uint
+	}{
+		{
+			name: "by user",
+			key:  ByUser,
+			want: [][]uint{{1, 3, 5}, {2, 4}},
+		},
+		{
+			name: "by card, whoever used it",
+			key:  ByCard,
+			want: [][]uint{{1, 2, 5}, {3, 4}},
+		},
+		{
+			name: "by device, the user agent standing for the missing device id",
+			key:  ByDevice,
+			want: [][]uint{{1, 3}, {2}},
+		},
+		{
+			name: "by IP address, leaving out transactions without one",
+			key:  ByIP,
+			want: [][]uint{{1, 3}, {5}},
+		},
+		{
+			name: "by merchant, leaving out transactions without one",
+			key:  ByMerchant,
+			want: [][]uint{{1, 3}, {2, 5}},
+		},
+		{
+			name: "by user and merchant",
+			key:  Composite(ByUser, ByMerchant),
+			want: [][]uint{{1, 3}, {2}, {5}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, groupIds(batch.groups(test.key)))
+		})
+	}
+}
+
+func TestComposite(t *testing.T) {
+	key := Composite(ByCard, ByIP)
+	assert.Equal(t, "card+ip", key.Name)
+
+	// every key must group the transaction
+	_, isGrouped := key.Key(Transaction{IdCardUsed: 1})
+	assert.False(t, isGrouped)
+
+	// the same values of different types are different groups
+	byString := GroupKey{Name: "string", Key: func(transaction Transaction) (any, bool) { return "1", true }}
+	first, _ := Composite(ByUser, byString).Key(Transaction{UserId: 1})
+	second, _ := Composite(byString, ByUser).Key(Transaction{UserId: 1})
+	assert.NotEqual(t, first, second)
+}
+
+func TestGroupKey_OrByUser(t *testing.T) {
+	assert.Equal(t, ByUser.Name, GroupKey{}.orByUser().Name)
+	assert.Equal(t, ByCard.Name, ByCard.orByUser().Name)
+}
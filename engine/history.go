@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// History remembers what previous assessments saw, letting rules look beyond the current batch.
+// It is kept in memory, each of its indexes holding a bounded number of cards, IP addresses, users or transactions
+// so a long running server does not grow without end, and is safe for concurrent use
+type History struct {
+	mutex sync.RWMutex
+	// users seen with each card id
+	cardUsers *recency[uint, mapset.Set[uint]]
+	// users seen with each IP address
+	ipUsers *recency[string, mapset.Set[uint]]
+	// device fingerprints seen for each user id
+	userDevices *recency[uint, mapset.Set[string]]
+	// latest amounts of each user id, oldest first, at most maxUserAmounts of them
+	userAmounts *recency[uint, []Money]
+	// user and card of each transaction id
+	transactionParties *recency[uint, transactionParties]
+}
+
+// who made a transaction
+type transactionParties struct {
+	userId uint
+	cardId uint
+}
+
+// how many amounts per user the history keeps, the longest window a spending profile can use
+const maxUserAmounts = 1000
+
+// DefaultHistoryLimit is how many cards, IP addresses, users and transactions NewHistory remembers, each
+const DefaultHistoryLimit = 100000
+
+// NewHistory creates an empty history remembering up to DefaultHistoryLimit keys in each index
+func NewHistory() *History {
+	return NewHistoryWithLimit(DefaultHistoryLimit)
+}
+
+// NewHistoryWithLimit creates an empty history remembering up to limit cards, IP addresses, users and transactions,
+// each, 0 for no limit. Once an index is full, the key least recently seen in an assessment is forgotten first
+func NewHistoryWithLimit(limit int) *History {
+	return &History{
+		cardUsers:          newRecency[uint, mapset.Set[uint]](limit),
+		ipUsers:            newRecency[string, mapset.Set[uint]](limit),
+		userDevices:        newRecency[uint, mapset.Set[string]](limit),
+		userAmounts:        newRecency[uint, []Money](limit),
+		transactionParties: newRecency[uint, transactionParties](limit),
+	}
+}
+
+// CardUsers returns a copy of the ids of the users seen with the card in previous assessments
+func (history *History) CardUsers(cardId uint) mapset.Set[uint] {
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	if cardUsers, isKnownCard := history.cardUsers.get(cardId); isKnownCard {
+		return cardUsers.Clone()
+	}
+	return mapset.NewSet[uint]()
+}
+
+// IPUsers returns a copy of the ids of the users seen with the IP address in previous assessments
+func (history *History) IPUsers(ipAddress string) mapset.Set[uint] {
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	if ipUsers, isKnownIP := history.ipUsers.get(ipAddress); isKnownIP {
+		return ipUsers.Clone()
+	}
+	return mapset.NewSet[uint]()
+}
+
+// UserDevices returns a copy of the device fingerprints seen for the user in previous assessments
+func (history *History) UserDevices(userId uint) mapset.Set[string] {
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	if devices, isKnownUser := history.userDevices.get(userId); isKnownUser {
+		return devices.Clone()
+	}
+	return mapset.NewSet[string]()
+}
+
+// UserAmounts returns a copy of the latest amounts of the user in previous assessments, oldest first
+func (history *History) UserAmounts(userId uint) []Money {
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	amounts, _ := history.userAmounts.get(userId)
+	return append([]Money(nil), amounts...)
+}
+
+// TransactionParties returns the user and the card of a transaction assessed before, the latest one when
+// the id was repeated
+func (history *History) TransactionParties(transactionId uint) (userId, cardId uint, isKnown bool) {
+	history.mutex.RLock()
+	defer history.mutex.RUnlock()
+
+	parties, isKnown := history.transactionParties.get(transactionId)
+	return parties.userId, parties.cardId, isKnown
+}
+
+// adds what the batch saw, called once every rule has evaluated it
+func (history *History) record(batch *Batch) {
+	history.mutex.Lock()
+	defer history.mutex.Unlock()
+
+	for cardId, users := range batch.CardUsers {
+		history.cardUsers.touch(cardId, newUserSet).Append(users.ToSlice()...)
+	}
+	for ipAddress, users := range batch.IPUsers {
+		history.ipUsers.touch(ipAddress, newUserSet).Append(users.ToSlice()...)
+	}
+	for userId, transactions := range batch.Users {
+		// amounts are kept in input order, so the window drops the oldest ones
+		userTransactions := transactions.ToSlice()
+		sort.Sort(TransactionsByPosition(userTransactions))
+		amounts := history.userAmounts.touch(userId, func() []Money { return nil })
+		for _, transaction := range userTransactions {
+			// the spending profile only holds money spent
+			if !transaction.Type.IsCredit() {
+				amounts = append(amounts, transaction.Amount)
+			}
+			history.transactionParties.set(transaction.TransactionId, transactionParties{userId: userId, cardId: transaction.IdCardUsed})
+		}
+		if overflow := len(amounts) - maxUserAmounts; overflow > 0 {
+			amounts = amounts[overflow:]
+		}
+		history.userAmounts.set(userId, amounts)
+
+		for _, transaction := range userTransactions {
+			if fingerprint := deviceFingerprint(transaction); fingerprint != "" {
+				history.userDevices.touch(userId, newDeviceSet).Add(fingerprint)
+			}
+		}
+	}
+}
+
+func newUserSet() mapset.Set[uint] {
+	return mapset.NewSet[uint]()
+}
+
+func newDeviceSet() mapset.Set[string] {
+	return mapset.NewSet[string]()
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"testing"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryCardUsers(t *testing.T) {
+	history := NewHistory()
+	assert.Equal(t, 0, history.CardUsers(1).Cardinality())
+
+	history.record(&Batch{CardUsers: UsersPerCardMap{1: mapset.NewSet[uint](10, 11)}})
+	history.record(&Batch{CardUsers: UsersPerCardMap{1: mapset.NewSet[uint](11, 12), 2: mapset.NewSet[uint](10)}})
+
+	assert.True(t, mapset.NewSet[uint](10, 11, 12).Equal(history.CardUsers(1)))
+	assert.True(t, mapset.NewSet[uint](10).Equal(history.CardUsers(2)))
+
+	// the returned set is a copy, changing it does not change the history
+	history.CardUsers(2).Add(99)
+	assert.Equal(t, 1, history.CardUsers(2).Cardinality())
+}
+
+func TestHistoryUserAmounts(t *testing.T) {
+	history := NewHistory()
+	assert.Empty(t, history.UserAmounts(1))
+
+	// recorded in input order, whatever the set order is
+	history.record(&Batch{Users: TransactionsPerUserMap{1: mapset.NewSet(
+		Transaction{UserId: 1, Amount: USD(30), LineNumber: 3},
+		Transaction{UserId: 1, Amount: USD(10), LineNumber: 1},
+		Transaction{UserId: 1, Amount: USD(20), LineNumber: 2},
+		// money given back is not spending
+		Transaction{UserId: 1, Amount: USD(5), LineNumber: 4, Type: Refund},
+	)}})
+	assert.Equal(t, usdAmounts(10, 20, 30), history.UserAmounts(1))
+
+	// only the latest maxUserAmounts are kept, so 10 and 20 are dropped
+	manyAmounts := mapset.NewSet[Transaction]()
+	for line := 1; line <= maxUserAmounts-1; line++ {
+		manyAmounts.Add(Transaction{UserId: 1, Amount: USD(100), LineNumber: line})
+	}
+	history.record(&Batch{Users: TransactionsPerUserMap{1: manyAmounts}})
+	got := history.UserAmounts(1)
+	assert.Len(t, got, maxUserAmounts)
+	assert.Equal(t, USD(30), got[0])
+}
+
+func TestHistoryTransactionParties(t *testing.T) {
+	history := NewHistory()
+	_, _, isKnown := history.TransactionParties(7)
+	assert.False(t, isKnown)
+
+	history.record(relateTransactions([]Transaction{{TransactionId: 7, UserId: 1, IdCardUsed: 3}}))
+	userId, cardId, isKnown := history.TransactionParties(7)
+	assert.True(t, isKnown)
+	assert.Equal(t, uint(1), userId)
+	assert.Equal(t, uint(3), cardId)
+}
+
+func TestHistory_Limit(t *testing.T) {
+	history := NewHistoryWithLimit(2)
+	history.record(relateTransactions([]Transaction{{TransactionId: 1, UserId: 1, IdCardUsed: 1, IPAddress: "192.0.2.1"}}))
+	history.record(relateTransactions([]Transaction{{TransactionId: 2, UserId: 2, IdCardUsed: 2, IPAddress: "192.0.2.2"}}))
+	// card 1 is seen again, so card 2 is now the least recent one
+	history.record(relateTransactions([]Transaction{{TransactionId: 3, UserId: 1, IdCardUsed: 1, IPAddress: "192.0.2.1"}}))
+	history.record(relateTransactions([]Transaction{{TransactionId: 4, UserId: 3, IdCardUsed: 3, IPAddress: "192.0.2.3"}}))
+
+	assert.Equal(t, 1, history.CardUsers(1).Cardinality())
+	assert.Equal(t, 0, history.CardUsers(2).Cardinality())
+	assert.Equal(t, 1, history.CardUsers(3).Cardinality())
+	assert.Equal(t, 0, history.IPUsers("192.0.2.2").Cardinality())
+	assert.Empty(t, history.UserAmounts(2))
+	assert.Len(t, history.UserAmounts(1), 2)
+	_, _, isKnown := history.TransactionParties(2)
+	assert.False(t, isKnown)
+	_, _, isKnown = history.TransactionParties(4)
+	assert.True(t, isKnown)
+
+	// no index grows past the limit
+	for _, size := range []int{history.cardUsers.len(), history.ipUsers.len(), history.userAmounts.len(), history.transactionParties.len()} {
+		assert.Equal(t, 2, size)
+	}
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// Raises to high the risk of transactions made in one of the high risk merchant categories
+func riskPerMerchantCategory(userTransactions []Transaction, highRiskCategories []string) {
+	categorySet := mapset.NewSet(highRiskCategories...)
+
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		if currentTransaction.MerchantCategoryCode != "" && categorySet.Contains(currentTransaction.MerchantCategoryCode) {
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, HIGH)
+		}
+	}
+}
+
+// Raises to medium the risk of the first transaction of the user with each merchant
+// when its amount is above newMerchantAmount. Transactions with unknown merchant are skipped
+func riskPerNewMerchant(userTransactions []Transaction, newMerchantAmount int64) {
+	// zero disables the rule
+	if newMerchantAmount <= 0 {
+		return
+	}
+	merchantIdSet := mapset.NewSet[uint]()
+
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		if currentTransaction.MerchantId == 0 {
+			continue
+		}
+
+		// Add returns false when the merchant was already in the set
+		isNewMerchant := merchantIdSet.Add(currentTransaction.MerchantId)
+		if isNewMerchant && currentTransaction.Amount.MinorUnits > newMerchantAmount {
+			currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, MEDIUM)
+		}
+	}
+}
+
+// Analyzes the amount of each transaction against the thresholds of its merchant category, if any
+func riskPerCategoryAmount(userTransactions []Transaction, categoryThresholds map[string]AmountThresholds) {
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		thresholds, hasThresholds := categoryThresholds[currentTransaction.MerchantCategoryCode]
+		if !hasThresholds {
+			continue
+		}
+
+		risk := LOW
+		if currentTransaction.Amount.MinorUnits > thresholds.High {
+			risk = HIGH
+		} else if currentTransaction.Amount.MinorUnits > thresholds.Medium {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
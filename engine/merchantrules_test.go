@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRiskPerMerchantCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		args []Transaction
+		want []Transaction
+	}{
+		{
+			name: "gambling should return high, groceries and unknown category should stay as they were",
+			args: []Transaction{
+				{MerchantCategoryCode: "7995"},
+				{MerchantCategoryCode: "5411"},
+				{MerchantCategoryCode: "", RiskRate: MEDIUM},
+			},
+			want: []Transaction{
+				{MerchantCategoryCode: "7995", RiskRate: HIGH},
+				{MerchantCategoryCode: "5411", RiskRate: LOW},
+				{MerchantCategoryCode: "", RiskRate: MEDIUM},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerMerchantCategory(test.args, []string{"7995", "6051"})
+			assert.Equal(t, test.want, test.args)
+		})
+	}
+}
+
+func TestRiskPerNewMerchant(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int64
+		args      []Transaction
+		want      []Transaction
+	}{
+		{
+			name:      "only the first transaction with each merchant above the threshold should return medium",
+			threshold: 300000,
+			args: []Transaction{
+				{MerchantId: 1, Amount: USD(300001)},
+				{MerchantId: 1, Amount: USD(400000)},
+				{MerchantId: 2, Amount: USD(300000)},
+				{MerchantId: 0, Amount: USD(900000)},
+			},
+			want: []Transaction{
+				{MerchantId: 1, Amount: USD(300001), RiskRate: MEDIUM},
+				{MerchantId: 1, Amount: USD(400000), RiskRate: LOW},
+				{MerchantId: 2, Amount: USD(300000), RiskRate: LOW},
+				{MerchantId: 0, Amount: USD(900000), RiskRate: LOW},
+			},
+		},
+		{
+			name:      "zero threshold should disable the rule",
+			threshold: 0,
+			args:      []Transaction{{MerchantId: 1, Amount: USD(900000)}},
+			want:      []Transaction{{MerchantId: 1, Amount: USD(900000), RiskRate: LOW}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerNewMerchant(test.args, test.threshold)
+			assert.Equal(t, test.want, test.args)
+		})
+	}
+}
+
+func TestRiskPerCategoryAmount(t *testing.T) {
+	thresholds := map[string]AmountThresholds{"5812": {Medium: 20000, High: 50000}}
+	args := []Transaction{
+		{MerchantCategoryCode: "5812", Amount: USD(20000)},
+		{MerchantCategoryCode: "5812", Amount: USD(20001)},
+		{MerchantCategoryCode: "5812", Amount: USD(50001)},
+		{MerchantCategoryCode: "5411", Amount: USD(50001)},
+	}
+	want := []Transaction{
+		{MerchantCategoryCode: "5812", Amount: USD(20000), RiskRate: LOW},
+		{MerchantCategoryCode: "5812", Amount: USD(20001), RiskRate: MEDIUM},
+		{MerchantCategoryCode: "5812", Amount: USD(50001), RiskRate: HIGH},
+		{MerchantCategoryCode: "5411", Amount: USD(50001), RiskRate: LOW},
+	}
+
+	riskPerCategoryAmount(args, thresholds)
+	assert.Equal(t, want, args)
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// ModelNode is a node of a decision tree: a split when Leaf is nil, a leaf otherwise
+type ModelNode struct {
+	// split nodes send the transaction to Left when the feature value is lower than Threshold, to Right otherwise
+	Feature   string  `json:"feature,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Left      int     `json:"left,omitempty"`
+	Right     int     `json:"right,omitempty"`
+	// value added to the score by leaf nodes
+	Leaf *float64 `json:"leaf,omitempty"`
+}
+
+// ModelTree is a decision tree, its root is the first node
+type ModelTree struct {
+	Nodes []ModelNode `json:"nodes"`
+}
+
+// Model is a gradient boosted ensemble of decision trees exported from an offline training. The fraud probability
+// is the sigmoid of BaseScore plus the leaf reached in every tree, as XGBoost and LightGBM binary classifiers do
+type Model struct {
+	Name      string      `json:"name"`
+	BaseScore float64     `json:"base_score"`
+	Trees     []ModelTree `json:"trees"`
+
+	// feature of each split node, resolved once by Compile
+	featureIndexes [][]int
+}
+
+// LoadModel reads a model from a JSON file, checking every split uses a known feature and valid children
+func LoadModel(path string) (*Model, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var model Model
+	if err := json.Unmarshal(content, &model); err != nil {
+		return nil, fmt.Errorf("parsing model %s: %w", path, err)
+	}
+	if err := model.Compile(); err != nil {
+		return nil, fmt.Errorf("model %s: %w", path, err)
+	}
+	return &model, nil
+}
+
+// Compile validates the trees and resolves the feature names of the split nodes. LoadModel calls it,
+// models built in code must call it before use
+func (model *Model) Compile() error {
+	if len(model.Trees) == 0 {
+		return fmt.Errorf("no trees")
+	}
+
+	model.featureIndexes = make([][]int, len(model.Trees))
+	for treeIndex, tree := range model.Trees {
+		if len(tree.Nodes) == 0 {
+			return fmt.Errorf("tree %d has no nodes", treeIndex)
+		}
+		model.featureIndexes[treeIndex] = make([]int, len(tree.Nodes))
+
+		for nodeIndex, node := range tree.Nodes {
+			if node.Leaf != nil {
+				continue
+			}
+			featureIndex, isKnownFeature := modelFeatureIndexes[node.Feature]
+			if !isKnownFeature {
+				return fmt.Errorf("tree %d node %d: unknown feature %q", treeIndex, nodeIndex, node.Feature)
+			}
+			// children must come after their parent, which also rules out cycles
+			for _, child := range []int{node.Left, node.Right} {
+				if child <= nodeIndex || child >= len(tree.Nodes) {
+					return fmt.Errorf("tree %d node %d: invalid child %d", treeIndex, nodeIndex, child)
+				}
+			}
+			model.featureIndexes[treeIndex][nodeIndex] = featureIndex
+		}
+	}
+	return nil
+}
+
+// Probability returns the fraud probability, between 0 and 1, for the feature values ordered as ModelFeatures
+func (model *Model) Probability(features []float64) float64 {
+	score := model.BaseScore
+	for treeIndex, tree := range model.Trees {
+		nodeIndex := 0
+		for tree.Nodes[nodeIndex].Leaf == nil {
+			node := tree.Nodes[nodeIndex]
+			if features[model.featureIndexes[treeIndex][nodeIndex]] < node.Threshold {
+				nodeIndex = node.Left
+			} else {
+				nodeIndex = node.Right
+			}
+		}
+		score += *tree.Nodes[nodeIndex].Leaf
+	}
+	return 1 / (1 + math.Exp(-score))
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// feature values in modelFeatures order, only the ones used by testdata/model.json are set
+func exampleFeatures(amount, distinctCards, newMerchant float64) []float64 {
+	features := make([]float64, len(modelFeatures))
+	features[modelFeatureIndexes["amount_us_cents"]] = amount
+	features[modelFeatureIndexes["user_distinct_cards"]] = distinctCards
+	features[modelFeatureIndexes["new_merchant"]] = newMerchant
+	return features
+}
+
+func TestLoadModel(t *testing.T) {
+	model, err := LoadModel(filepath.Join("testdata", "model.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "example-gbm", model.Name)
+
+	tests := []struct {
+		name     string
+		features []float64
+		want     float64
+	}{
+		// sigmoid(-2 - 1 + 0)
+		{name: "small amount", features: exampleFeatures(1000, 1, 0), want: 0.0474},
+		// sigmoid(-2 + 1.5 + 0.5)
+		{name: "large amount at a new merchant", features: exampleFeatures(300000, 1, 1), want: 0.5},
+		// sigmoid(-2 + 3 + 0.5)
+		{name: "large amount, many cards, new merchant", features: exampleFeatures(500000, 3, 1), want: 0.8176},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.InDelta(t, test.want, model.Probability(test.features), 0.0001)
+		})
+	}
+}
+
+func TestLoadModel_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "invalid JSON", content: `{"trees": [`, wantErr: "parsing model"},
+		{name: "no trees", content: `{"trees": []}`, wantErr: "no trees"},
+		{name: "empty tree", content: `{"trees": [{"nodes": []}]}`, wantErr: "tree 0 has no nodes"},
+		{
+			name:    "unknown feature",
+			content: `{"trees": [{"nodes": [{"feature": "shoe_size", "threshold": 1, "left": 1, "right": 2}, {"leaf": 1}, {"leaf": 2}]}]}`,
+			wantErr: `unknown feature "shoe_size"`,
+		},
+		{
+			name:    "child pointing back to its parent",
+			content: `{"trees": [{"nodes": [{"feature": "amount_us_cents", "threshold": 1, "left": 0, "right": 1}, {"leaf": 1}]}]}`,
+			wantErr: "invalid child 0",
+		},
+		{
+			name:    "child out of the tree",
+			content: `{"trees": [{"nodes": [{"feature": "amount_us_cents", "threshold": 1, "left": 1, "right": 5}, {"leaf": 1}]}]}`,
+			wantErr: "invalid child 5",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "model.json")
+			if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadModel(path)
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package engine
+
+import (
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// names models can split on, in the order Probability expects their values
+var modelFeatures = []string{
+	// the transaction amount
+	"amount_us_cents",
+	// sum of the user's amounts in the batch, up to and including this transaction
+	"user_running_total_us_cents",
+	// position among the user's transactions in the batch, starting at 1
+	"user_transaction_index",
+	// how many transactions the user has in the batch up to and including this one, the index under its older name
+	"user_transaction_count",
+	// distinct cards used by the user in the batch, up to and including this transaction
+	"user_distinct_cards",
+	// mean amount of the user's transactions in the batch, up to and including this transaction: the running total,
+	// net of refunds and reversals, over the transactions that are neither. 0 before the first of those
+	"user_mean_amount_us_cents",
+	// users seen with the card in the history and in the batch, up to and including this transaction
+	"card_users",
+	// 1 for the user's first transaction of the batch with a known merchant, 0 otherwise
+	"new_merchant",
+}
+
+// position of each feature name in modelFeatures
+var modelFeatureIndexes = func() map[string]int {
+	indexes := make(map[string]int, len(modelFeatures))
+	for index, name := range modelFeatures {
+		indexes[name] = index
+	}
+	return indexes
+}()
+
+// computes the feature values of each transaction from the transaction itself and the user's batch aggregates
+func modelFeatureValues(userTransactions []Transaction, batch *Batch) [][]float64 {
+	totals, err := runningTotals(userTransactions)
+	if err != nil || len(totals) == 0 {
+		// the engine refuses batches whose sums overflow before any rule runs
+		return nil
+	}
+	values := make([][]float64, len(userTransactions))
+	cardUserLines := batch.cardUserLines(userTransactions)
+	cardIdSet := mapset.NewSet[uint]()
+	merchantIdSet := mapset.NewSet[uint]()
+	debits := 0
+
+	for transacIndex, transaction := range userTransactions {
+		cardIdSet.Add(transaction.IdCardUsed)
+		if !transaction.Type.IsCredit() {
+			debits++
+		}
+		meanAmount := 0.0
+		if debits > 0 {
+			meanAmount = float64(totals[transacIndex].MinorUnits) / float64(debits)
+		}
+		newMerchant := 0.0
+		if transaction.MerchantId != 0 && merchantIdSet.Add(transaction.MerchantId) {
+			newMerchant = 1
+		}
+
+		values[transacIndex] = []float64{
+			float64(transaction.Amount.MinorUnits),
+			float64(totals[transacIndex].MinorUnits),
+			// never the later transactions, a batch scores as if its transactions came one by one
+			float64(transacIndex + 1),
+			float64(transacIndex + 1),
+			float64(cardIdSet.Cardinality()),
+			meanAmount,
+			float64(batch.cardUsersUntil(transaction, cardUserLines)),
+			newMerchant,
+		}
+	}
+	return values
+}
+
+// returns, for each card of the user's transactions, the first line of the batch where each user appeared with it
+func (batch *Batch) cardUserLines(userTransactions []Transaction) map[uint]map[uint]int {
+	cardUserLines := make(map[uint]map[uint]int)
+	for _, transaction := range userTransactions {
+		cardUserLines[transaction.IdCardUsed] = make(map[uint]int)
+	}
+	for userId, transactions := range batch.Users {
+		for transaction := range transactions.Iter() {
+			userLines, isUserCard := cardUserLines[transaction.IdCardUsed]
+			if !isUserCard {
+				continue
+			}
+			if line, isSeen := userLines[userId]; !isSeen || transaction.LineNumber < line {
+				userLines[userId] = transaction.LineNumber
+			}
+		}
+	}
+	return cardUserLines
+}
+
+// counts the users seen with the transaction card in the history and in the batch up to the transaction line
+func (batch *Batch) cardUsersUntil(transaction Transaction, cardUserLines map[uint]map[uint]int) int {
+	cardUsers := mapset.NewSet[uint](transaction.UserId)
+	for userId, line := range cardUserLines[transaction.IdCardUsed] {
+		if line <= transaction.LineNumber {
+			cardUsers.Add(userId)
+		}
+	}
+	if batch.History != nil {
+		cardUsers = cardUsers.Union(batch.History.CardUsers(transaction.IdCardUsed))
+	}
+	return cardUsers.Cardinality()
+}
+
+// Scores each transaction with the model, updating its Risk Level according to the probability thresholds
+func riskPerModel(userTransactions []Transaction, batch *Batch, modelConfig ModelConfig) {
+	if modelConfig.Model == nil || len(userTransactions) == 0 {
+		return
+	}
+
+	for transacIndex, features := range modelFeatureValues(userTransactions, batch) {
+		currentTransaction := &userTransactions[transacIndex]
+		probability := modelConfig.Model.Probability(features)
+
+		risk := LOW
+		if probability >= modelConfig.HighProbability {
+			risk = HIGH
+		} else if probability >= modelConfig.MediumProbability {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelFeatureValues(t *testing.T) {
+	userTransactions := []Transaction{
+		{UserId: 1, Amount: USD(100), IdCardUsed: 1, MerchantId: 9, LineNumber: 1},
+		{UserId: 1, Amount: USD(300), IdCardUsed: 2, MerchantId: 9, LineNumber: 3},
+		{UserId: 1, Amount: USD(100), IdCardUsed: 2, Type: Refund, LineNumber: 4},
+	}
+	batch := &Batch{Users: TransactionsPerUserMap{
+		1: mapset.NewSet(userTransactions...),
+		// seen with the second card before it, then after it
+		2: mapset.NewSet(Transaction{UserId: 2, Amount: USD(50), IdCardUsed: 2, LineNumber: 2}),
+		3: mapset.NewSet(Transaction{UserId: 3, Amount: USD(50), IdCardUsed: 2, LineNumber: 5}),
+	}}
+
+	got := modelFeatureValues(userTransactions, batch)
+	assert.Equal(t, [][]float64{
+		{100, 100, 1, 1, 1, 100, 1, 1},
+		{300, 400, 2, 2, 2, 200, 2, 0},
+		// the refund lowers the total but is not counted in the mean
+		{100, 300, 3, 3, 2, 150, 2, 0},
+	}, got)
+	assert.Len(t, got[0], len(modelFeatures))
+}
+
+func TestModelFeatureValues_CardUsersHistory(t *testing.T) {
+	history := NewHistory()
+	history.record(&Batch{CardUsers: UsersPerCardMap{1: mapset.NewSet[uint](7)}})
+	userTransactions := []Transaction{{UserId: 1, Amount: USD(100), IdCardUsed: 1, LineNumber: 1}}
+	batch := &Batch{Users: TransactionsPerUserMap{1: mapset.NewSet(userTransactions...)}, History: history}
+
+	got := modelFeatureValues(userTransactions, batch)
+	assert.Equal(t, 2.0, got[0][modelFeatureIndexes["card_users"]])
+}
+
+func TestRiskPerModel(t *testing.T) {
+	model, err := LoadModel(filepath.Join("testdata", "model.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelConfig := ModelConfig{MediumProbability: 0.5, HighProbability: 0.8, Model: model}
+
+	tests := []struct {
+		name string
+		args []Transaction
+		want []RiskLevel
+	}{
+		{
+			name: "small amounts should return low",
+			args: []Transaction{{IdCardUsed: 1, Amount: USD(1000)}},
+			want: []RiskLevel{LOW},
+		},
+		{
+			name: "large amount at a new merchant should return medium, then high once a second card is used",
+			args: []Transaction{
+				{IdCardUsed: 1, Amount: USD(300000), MerchantId: 1},
+				{IdCardUsed: 2, Amount: USD(500000), MerchantId: 2},
+			},
+			want: []RiskLevel{MEDIUM, HIGH},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerModel(test.args, &Batch{}, modelConfig)
+			assert.Equal(t, test.want, riskLevels(test.args))
+		})
+	}
+}
+
+func TestRiskPerModel_NoModel(t *testing.T) {
+	args := []Transaction{{Amount: USD(900000)}}
+	riskPerModel(args, &Batch{}, ModelConfig{})
+	assert.Equal(t, []RiskLevel{LOW}, riskLevels(args))
+}
@@ -0,0 +1,58 @@
+package engine
+
+import "container/list"
+
+// recency maps keys to values, keeping at most limit keys, or any number of them when limit is 0: once full, adding
+// a key forgets the one least recently touched. It is not safe for concurrent use, History guards it
+type recency[K comparable, V any] struct {
+	limit   int
+	entries map[K]*list.Element
+	// of recencyEntry, the most recently touched first
+	order *list.List
+}
+
+type recencyEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newRecency[K comparable, V any](limit int) *recency[K, V] {
+	return &recency[K, V]{limit: limit, entries: make(map[K]*list.Element), order: list.New()}
+}
+
+// get returns the value of the key without touching it, so reads do not keep keys alive
+func (recency *recency[K, V]) get(key K) (V, bool) {
+	element, isKnown := recency.entries[key]
+	if !isKnown {
+		var none V
+		return none, false
+	}
+	return element.Value.(*recencyEntry[K, V]).value, true
+}
+
+// touch returns the value of the key, created by newValue when missing, and makes it the most recent one
+func (recency *recency[K, V]) touch(key K, newValue func() V) V {
+	if element, isKnown := recency.entries[key]; isKnown {
+		recency.order.MoveToFront(element)
+		return element.Value.(*recencyEntry[K, V]).value
+	}
+	value := newValue()
+	recency.entries[key] = recency.order.PushFront(&recencyEntry[K, V]{key: key, value: value})
+	for recency.limit > 0 && recency.order.Len() > recency.limit {
+		oldest := recency.order.Back()
+		recency.order.Remove(oldest)
+		delete(recency.entries, oldest.Value.(*recencyEntry[K, V]).key)
+	}
+	return value
+}
+
+// set replaces the value of the key, making it the most recent one
+func (recency *recency[K, V]) set(key K, value V) {
+	recency.touch(key, func() V { return value })
+	recency.entries[key].Value.(*recencyEntry[K, V]).value = value
+}
+
+// len returns how many keys are kept
+func (recency *recency[K, V]) len() int {
+	return recency.order.Len()
+}
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecency(t *testing.T) {
+	zero := func() int { return 0 }
+	recency := newRecency[string, int](2)
+	recency.set("a", 1)
+	recency.set("b", 2)
+	// reading does not make a key recent, touching does
+	recency.get("a")
+	recency.touch("b", zero)
+	recency.set("c", 3)
+
+	_, isKnown := recency.get("a")
+	assert.False(t, isKnown)
+	value, isKnown := recency.get("b")
+	assert.True(t, isKnown)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, 3, recency.touch("c", zero))
+	assert.Equal(t, 2, recency.len())
+
+	unlimited := newRecency[int, int](0)
+	for key := 0; key < 100; key++ {
+		unlimited.set(key, key)
+	}
+	assert.Equal(t, 100, unlimited.len())
+}
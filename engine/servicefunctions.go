@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	. "transactionriskassessment/domain"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+// RelateUserToTransactions maps each unique user id to their corresponding set of transactions
+func RelateUserToTransactions(transactionSlice []Transaction) TransactionsPerUserMap {
+	return relateTransactions(transactionSlice).Users
+}
+
+// relateTransactions maps each user id to their transactions and, in the same pass, builds the
+// cross-user indexes relating each card id and each IP address to the users that used them
+func relateTransactions(transactionSlice []Transaction) *Batch {
+	// unique user ids in transaction list
+	userIdSet := mapset.NewSet[uint]()
+	// set with unique transactions, being instantiated for each new user
+	var transactionSet mapset.Set[Transaction]
+
+	// relating each user to their transactions
+	userAndTransct := make(TransactionsPerUserMap)
+	// relating each card and each IP address to the users that used them
+	cardAndUsers := make(UsersPerCardMap)
+	ipAndUsers := make(UsersPerIPMap)
+
+	for transactionIndex := range transactionSlice {
+		// saving position that it was in the json
+		currentTransaction := &transactionSlice[transactionIndex]
+		transactionSlice[transactionIndex].LineNumber = transactionIndex + 1
+
+		// first time seeing this user id
+		if !userIdSet.Contains(currentTransaction.UserId) {
+			// add to the known ids set
+			userIdSet.Add(currentTransaction.UserId)
+			// new transaction set for new user
+			transactionSet = mapset.NewSet[Transaction]()
+			// the first transaction for this user in their set
+			transactionSet.Add(*currentTransaction)
+
+			// assign set for UserId key
+			userAndTransct[currentTransaction.UserId] = transactionSet
+		} else {
+			userSet := userAndTransct[currentTransaction.UserId]
+			userSet.Add(*currentTransaction)
+		}
+
+		addCardUser(cardAndUsers, currentTransaction.IdCardUsed, currentTransaction.UserId)
+		addIPUser(ipAndUsers, currentTransaction.IPAddress, currentTransaction.UserId)
+	}
+	return &Batch{Users: userAndTransct, CardUsers: cardAndUsers, IPUsers: ipAndUsers}
+}
+
+// relates the user to the card in the index, creating the card's set on its first use
+func addCardUser(cardAndUsers UsersPerCardMap, cardId, userId uint) {
+	cardUsers, isKnownCard := cardAndUsers[cardId]
+	if !isKnownCard {
+		cardUsers = mapset.NewSet[uint]()
+		cardAndUsers[cardId] = cardUsers
+	}
+	cardUsers.Add(userId)
+}
+
+// relates the user to the IP address in the index, transactions without IP address are left out
+func addIPUser(ipAndUsers UsersPerIPMap, ipAddress string, userId uint) {
+	if ipAddress == "" {
+		return
+	}
+	ipUsers, isKnownIP := ipAndUsers[ipAddress]
+	if !isKnownIP {
+		ipUsers = mapset.NewSet[uint]()
+		ipAndUsers[ipAddress] = ipUsers
+	}
+	ipUsers.Add(userId)
+}
+
+// builds the batch, with its cross-user indexes, from transactions already grouped by user
+func batchFromUsers(userTransactions TransactionsPerUserMap) *Batch {
+	batch := &Batch{Users: userTransactions, CardUsers: make(UsersPerCardMap), IPUsers: make(UsersPerIPMap)}
+	for userId, transactions := range userTransactions {
+		for transaction := range transactions.Iter() {
+			addCardUser(batch.CardUsers, transaction.IdCardUsed, userId)
+			addIPUser(batch.IPUsers, transaction.IPAddress, userId)
+		}
+	}
+	return batch
+}
+
+// Checks which risk is greater, returning the greater one.
+func greaterRisk(currentRisk, newRisk RiskLevel) RiskLevel {
+	if currentRisk < newRisk {
+		return newRisk
+	}
+	return currentRisk
+}
+
+// Analyzes the amount of each transaction, updating their Risk Level according to the risk rules.
+// Refunds and reversals give money back, they are not analyzed
+func riskPerSingleAmount(userTransactions []Transaction) {
+
+	for transacIndex := range userTransactions {
+		// shortening the name reference of memory space
+		currentTransaction := &userTransactions[transacIndex]
+		if currentTransaction.Type.IsCredit() {
+			continue
+		}
+		// standard risk if no match for risk rules
+		risk := LOW
+		if currentTransaction.Amount.MinorUnits > HighRiskSingleAmount {
+			risk = HIGH
+		} else if currentTransaction.Amount.MinorUnits > MediumRiskSingleAmount {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(userTransactions[transacIndex].RiskRate, risk)
+	}
+}
+
+// Analyzes the amount of each transaction against the thresholds of its type, if any
+func riskPerTypeAmount(userTransactions []Transaction, typeThresholds map[TransactionType]AmountThresholds) {
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		thresholds, hasThresholds := typeThresholds[currentTransaction.Type.OrPurchase()]
+		if !hasThresholds {
+			continue
+		}
+
+		risk := LOW
+		if currentTransaction.Amount.MinorUnits > thresholds.High {
+			risk = HIGH
+		} else if currentTransaction.Amount.MinorUnits > thresholds.Medium {
+			risk = MEDIUM
+		}
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
+
+// runningTotals returns what was spent up to and including each transaction, of a user or of a card: refunds are
+// subtracted, and a reversal takes back what the transaction it references, earlier in the batch, added. It returns
+// an error when a sum overflows or mixes currencies, the engine refuses such batches before any rule runs
+func runningTotals(userTransactions []Transaction) ([]Money, error) {
+	totals := make([]Money, len(userTransactions))
+	// what each earlier transaction added to the total, until it is reversed
+	contributions := make(map[uint]Money, len(userTransactions))
+	var totalAmount Money
+	for transacIndex, transaction := range userTransactions {
+		var contribution Money
+		var err error
+		switch transaction.Type {
+		case Reversal:
+			// transactions not in the batch, or already reversed, have nothing to take back
+			contribution, err = Money{}.Sub(contributions[transaction.ReferenceId])
+			delete(contributions, transaction.ReferenceId)
+		case Refund:
+			contribution, err = Money{}.Sub(transaction.Amount)
+		default:
+			contribution = transaction.Amount
+		}
+		if err == nil {
+			totalAmount, err = totalAmount.Add(contribution)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", transaction.TransactionId, err)
+		}
+
+		if transaction.Type != Reversal {
+			contributions[transaction.TransactionId] = contribution
+		}
+		totals[transacIndex] = totalAmount
+	}
+	return totals, nil
+}
+
+// Sums up the dollar amount while iterating through user transactions
+// updating their Risk Level according to the risk rules
+func riskPerTotalAmount(userTransactions []Transaction) {
+	totals, err := runningTotals(userTransactions)
+	if err != nil {
+		// the engine refuses these batches before any rule runs
+		return
+	}
+
+	// to update values from slice input, do not use the second return of range
+	// it's a copy of the element of the slice, not a reference.
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		totalAmount := totals[transacIndex].MinorUnits
+
+		risk := LOW
+		if totalAmount > MediumRiskTotalAmount && totalAmount <= HighRiskTotalAmount {
+			risk = MEDIUM
+		} else if totalAmount > HighRiskTotalAmount {
+			risk = HIGH
+		}
+
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
+
+// Checks how many different cards are in the user transaction set
+// updating each transaction Risk Level according to the risk rules
+func riskPerMultipleCards(userTransactions []Transaction) {
+	cardIdSet := mapset.NewSet[uint]()
+
+	for transacIndex := range userTransactions {
+		currentTransaction := &userTransactions[transacIndex]
+		isCardInSet := cardIdSet.Contains(currentTransaction.IdCardUsed)
+
+		if !isCardInSet {
+			cardIdSet.Add(currentTransaction.IdCardUsed)
+		}
+
+		risk := LOW
+		if cardIdSet.Cardinality() <= 1 {
+			risk = LOW
+		} else if cardIdSet.Cardinality() == 2 {
+			risk = MEDIUM
+		} else {
+			risk = HIGH
+		}
+
+		currentTransaction.RiskRate = greaterRisk(currentTransaction.RiskRate, risk)
+	}
+}
+
+// Receives a slice with all transactions that were sent to the API
+// with risks calculated and returns a slice with resultant risk in strings
+// sorted by transaction id * might change
+func allTransactionsRisk(allTransactions []Transaction) RiskRateResults {
+	var calcRiskRates RiskRateResults
+	var risksSlice []string
+
+	// sorting transactions by input position to make result position match
+	sort.Sort(TransactionsByPosition(allTransactions))
+
+	for _, transac := range allTransactions {
+		risksSlice = append(risksSlice, transac.RiskRate.String())
+	}
+	calcRiskRates.RiskRates = risksSlice
+
+	return calcRiskRates
+}
+
+// Receives the input transactions, returns a slice with each transaction risk level ordered by transaction line number.
+// It returns an error, and no ratings, when a user or card sum overflows or mixes currencies
+func CheckTransactions(userTransactions TransactionsPerUserMap) (RiskRateResults, error) {
+	// the background context is never canceled, only the sums can fail
+	assessments, err := New().checkTransactions(context.Background(), batchFromUsers(userTransactions))
+	if err != nil {
+		return RiskRateResults{}, err
+	}
+	return Results(assessments), nil
+}
@@ -0,0 +1,451 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+
+	mapset "github.com/deckarep/golang-set/v2"
+)
+
+/** Mocks for test cases*/
+var transactionsListMock = []Transaction{
+	{TransactionId: 1, UserId: 1, Amount: USD(200000), IdCardUsed: 1, LineNumber: 1},
+	{TransactionId: 2, UserId: 1, Amount: USD(600000), IdCardUsed: 1, LineNumber: 2},
+	{TransactionId: 3, UserId: 3, Amount: USD(1100000), IdCardUsed: 1, LineNumber: 3},
+	{TransactionId: 4, UserId: 2, Amount: USD(100000), IdCardUsed: 2, LineNumber: 4},
+	{TransactionId: 5, UserId: 2, Amount: USD(100000), IdCardUsed: 3, LineNumber: 5},
+	{TransactionId: 6, UserId: 2, Amount: USD(100000), IdCardUsed: 4, LineNumber: 6},
+}
+var expectedSetsUser1 = mapset.NewSet(transactionsListMock[:2]...)
+var expectedSetsUser2 = mapset.NewSet(transactionsListMock[3:]...)
+var expectedSetsUser3 = mapset.NewSet(transactionsListMock[2])
+
+var expectedMap = map[uint]mapset.Set[Transaction]{
+	1: expectedSetsUser1,
+	2: expectedSetsUser2,
+	3: expectedSetsUser3,
+}
+
+func TestRelateUserToTransactions(t *testing.T) {
+	type args struct {
+		transactions []Transaction
+	}
+	// test case definition
+	tests := []struct {
+		name string
+		args args
+		want TransactionsPerUserMap
+	}{
+		// test cases
+		{
+			name: "should return a map with three keys for each user and transactions' set for each key",
+			args: args{transactionsListMock},
+			want: expectedMap,
+		},
+	}
+	// test cases execution
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RelateUserToTransactions(tt.args.transactions)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRelateTransactions_CardIndex(t *testing.T) {
+	// the mock has card 1 used by users 1 and 3
+	transactions := make([]Transaction, len(transactionsListMock))
+	copy(transactions, transactionsListMock)
+
+	batch := relateTransactions(transactions)
+	assert.Equal(t, TransactionsPerUserMap(expectedMap), batch.Users)
+	assert.Equal(t, UsersPerCardMap{
+		1: mapset.NewSet[uint](1, 3),
+		2: mapset.NewSet[uint](2),
+		3: mapset.NewSet[uint](2),
+		4: mapset.NewSet[uint](2),
+	}, batch.CardUsers)
+	// no transaction has an IP address
+	assert.Empty(t, batch.IPUsers)
+	// building it from the user map gives the same indexes
+	assert.Equal(t, batch, batchFromUsers(batch.Users))
+}
+
+func TestGreaterRisk(t *testing.T) {
+	type args struct {
+		riskLevel1 RiskLevel
+		riskLevel2 RiskLevel
+	}
+	tests := []struct {
+		name string
+		args args
+		want RiskLevel
+	}{
+		{
+			name: "should return HIGH",
+			args: args{LOW, HIGH},
+			want: HIGH,
+		},
+		{
+			name: "should return MEDIUM",
+			args: args{MEDIUM, LOW},
+			want: MEDIUM,
+		},
+		{
+			name: "should return LOW",
+			args: args{LOW, LOW},
+			want: LOW,
+		},
+		{
+			name: "should return HIGH",
+			args: args{HIGH, MEDIUM},
+			want: HIGH,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := greaterRisk(test.args.riskLevel1, test.args.riskLevel2)
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+}
+
+func TestRiskPerSingleAmount(t *testing.T) {
+	tests := []struct {
+		name string
+		args []Transaction
+		// this function updates the risk field in the transactions passed
+		want []Transaction
+	}{
+		{
+			name: "should return low for values <= $5000 transactions",
+			args: []Transaction{
+				{Amount: USD(312000)},
+				{Amount: USD(499999)},
+				{Amount: USD(0)},
+			},
+			want: []Transaction{
+				{RiskRate: LOW, Amount: USD(312000)},
+				{RiskRate: LOW, Amount: USD(499999)},
+				{RiskRate: LOW, Amount: USD(0)},
+			},
+		},
+		{
+			name: "should return medium for values between $5000.01 and $10000 transactions",
+			args: []Transaction{
+				{Amount: USD(999999)},
+				{Amount: USD(500001)},
+				{Amount: USD(700000)},
+			},
+			want: []Transaction{
+				{RiskRate: MEDIUM, Amount: USD(999999)},
+				{RiskRate: MEDIUM, Amount: USD(500001)},
+				{RiskRate: MEDIUM, Amount: USD(700000)},
+			},
+		},
+		{
+			name: "should return high for values greater than $10000 transactions",
+			args: []Transaction{
+				{Amount: USD(5000000)},
+				{Amount: USD(1500000)},
+				{Amount: USD(1100000)},
+			},
+			want: []Transaction{
+				{RiskRate: HIGH, Amount: USD(5000000)},
+				{RiskRate: HIGH, Amount: USD(1500000)},
+				{RiskRate: HIGH, Amount: USD(1100000)},
+			},
+		},
+		{
+			name: "should return low, medium and high",
+			args: []Transaction{
+				{Amount: USD(500000)},
+				{Amount: USD(1000000)},
+				{Amount: USD(1000001)},
+			},
+			want: []Transaction{
+				{RiskRate: LOW, Amount: USD(500000)},
+				{RiskRate: MEDIUM, Amount: USD(1000000)},
+				{RiskRate: HIGH, Amount: USD(1000001)},
+			},
+		},
+		{
+			name: "should ignore refunds and reversals",
+			args: []Transaction{
+				{Amount: USD(1000001), Type: Refund},
+				{Amount: USD(1000001), Type: Reversal, ReferenceId: 1},
+				{Amount: USD(1000001), Type: CashWithdrawal},
+			},
+			want: []Transaction{
+				{RiskRate: LOW, Amount: USD(1000001), Type: Refund},
+				{RiskRate: LOW, Amount: USD(1000001), Type: Reversal, ReferenceId: 1},
+				{RiskRate: HIGH, Amount: USD(1000001), Type: CashWithdrawal},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerSingleAmount(test.args)
+			// should've been updated
+			got := test.args
+			assert.Equal(t, test.want, got)
+
+		})
+	}
+
+}
+
+func TestRiskPerTypeAmount(t *testing.T) {
+	thresholds := map[TransactionType]AmountThresholds{CashWithdrawal: {Medium: 50000, High: 100000}, Purchase: {Medium: 200000, High: 300000}}
+	args := []Transaction{
+		{Type: CashWithdrawal, Amount: USD(50000)},
+		{Type: CashWithdrawal, Amount: USD(50001)},
+		{Type: CashWithdrawal, Amount: USD(100001)},
+		// transactions without a type are purchases
+		{Amount: USD(200001)},
+		{Type: Transfer, Amount: USD(300001)},
+	}
+	want := []Transaction{
+		{Type: CashWithdrawal, Amount: USD(50000), RiskRate: LOW},
+		{Type: CashWithdrawal, Amount: USD(50001), RiskRate: MEDIUM},
+		{Type: CashWithdrawal, Amount: USD(100001), RiskRate: HIGH},
+		{Amount: USD(200001), RiskRate: MEDIUM},
+		{Type: Transfer, Amount: USD(300001), RiskRate: LOW},
+	}
+
+	riskPerTypeAmount(args, thresholds)
+	assert.Equal(t, want, args)
+}
+
+func TestRunningTotals(t *testing.T) {
+	got, err := runningTotals([]Transaction{{Amount: USD(100)}, {Amount: USD(-30)}, {Amount: USD(5)}})
+	assert.NoError(t, err)
+	assert.Equal(t, usdAmounts(100, 70, 75), got)
+
+	_, err = runningTotals([]Transaction{{TransactionId: 1, Amount: USD(math.MaxInt64)}, {TransactionId: 2, Amount: USD(1)}})
+	assert.ErrorIs(t, err, ErrMoneyOverflow)
+	assert.ErrorContains(t, err, "transaction 2: ")
+
+	_, err = runningTotals([]Transaction{{Amount: USD(1)}, {Amount: Money{MinorUnits: 1, Currency: "EUR"}}})
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	got, err = runningTotals([]Transaction{
+		{TransactionId: 1, Amount: USD(100)},
+		{TransactionId: 2, Amount: USD(40), Type: Refund},
+		// takes the refund back
+		{TransactionId: 3, Type: Reversal, ReferenceId: 2},
+		// a transaction is only reversed once
+		{TransactionId: 4, Type: Reversal, ReferenceId: 2},
+		// not in the batch, nothing to take back
+		{TransactionId: 5, Type: Reversal, ReferenceId: 99},
+		{TransactionId: 6, Amount: USD(50), Type: CashWithdrawal},
+		{TransactionId: 7, Type: Reversal, ReferenceId: 1, Amount: USD(100)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, usdAmounts(100, 60, 100, 100, 100, 150, 50), got)
+}
+
+func TestRiskPerTotalAmount(t *testing.T) {
+	tests := []struct {
+		name string
+		args []Transaction
+		// this function updates the risk field in the transactions passed
+		want []Transaction
+	}{
+		{
+			name: "The accumulating values should return LOW, MEDIUM and HIGH",
+			args: []Transaction{
+				{Amount: USD(1000000)},
+				{Amount: USD(500000)},
+				{Amount: USD(500010)},
+			},
+			want: []Transaction{
+				{RiskRate: LOW, Amount: USD(1000000)},
+				{RiskRate: MEDIUM, Amount: USD(500000)},
+				{RiskRate: HIGH, Amount: USD(500010)},
+			},
+		},
+		{
+			name: "Accumulating values varying, should return HIGH, MEDIUM and LOW",
+			args: []Transaction{
+				// should not update a higher risk to a medium
+				{Amount: USD(1100000), RiskRate: HIGH},
+				// medium since 16k is between 10k and 20k
+				{Amount: USD(500000)},
+				// this shouldn't be possible, but currently is. so risklevel should be low (for the total amount rules)
+				{Amount: USD(-1000000)},
+			},
+			want: []Transaction{
+				{RiskRate: HIGH, Amount: USD(1100000)},
+				{RiskRate: MEDIUM, Amount: USD(500000)},
+				{RiskRate: LOW, Amount: USD(-1000000)},
+			},
+		},
+		{
+			name: "Refunds and reversals should lower the total",
+			args: []Transaction{
+				{TransactionId: 1, Amount: USD(1500000)},
+				// back to $7000
+				{TransactionId: 2, Amount: USD(800000), Type: Refund},
+				{TransactionId: 3, Amount: USD(900000)},
+				// cancels transaction 3, back to $7000
+				{TransactionId: 4, Amount: USD(900000), Type: Reversal, ReferenceId: 3},
+				{TransactionId: 5, Amount: USD(1400000)},
+			},
+			want: []Transaction{
+				{TransactionId: 1, Amount: USD(1500000), RiskRate: MEDIUM},
+				{TransactionId: 2, Amount: USD(800000), Type: Refund, RiskRate: LOW},
+				{TransactionId: 3, Amount: USD(900000), RiskRate: MEDIUM},
+				{TransactionId: 4, Amount: USD(900000), Type: Reversal, ReferenceId: 3, RiskRate: LOW},
+				{TransactionId: 5, Amount: USD(1400000), RiskRate: HIGH},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerTotalAmount(test.args)
+			// should've been updated
+			got := test.args
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestRiskPerMultipleCards(t *testing.T) {
+	tests := []struct {
+		name string
+		args []Transaction
+		// this function updates the risk field in the transactions passed
+		want []Transaction
+	}{
+		{
+			name: "Same cardId, should return low 3x",
+			args: []Transaction{
+				{IdCardUsed: 1},
+				{IdCardUsed: 1},
+				{IdCardUsed: 1},
+			},
+			want: []Transaction{
+				{RiskRate: LOW, IdCardUsed: 1},
+				{RiskRate: LOW, IdCardUsed: 1},
+				{RiskRate: LOW, IdCardUsed: 1},
+			},
+		},
+		{
+			name: "Two distinct cardIds, should return low, medium and medium",
+			args: []Transaction{
+				{IdCardUsed: 1},
+				{IdCardUsed: 2},
+				{IdCardUsed: 1},
+			},
+			want: []Transaction{
+				{RiskRate: LOW, IdCardUsed: 1},
+				{RiskRate: MEDIUM, IdCardUsed: 2},
+				{RiskRate: MEDIUM, IdCardUsed: 1},
+			},
+		},
+		{
+			name: "Three distinct cardIds, should return low, medium, high",
+			args: []Transaction{
+				{IdCardUsed: 1},
+				{IdCardUsed: 2},
+				{IdCardUsed: 3},
+			},
+			want: []Transaction{
+				{RiskRate: LOW, IdCardUsed: 1},
+				{RiskRate: MEDIUM, IdCardUsed: 2},
+				{RiskRate: HIGH, IdCardUsed: 3},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			riskPerMultipleCards(test.args)
+			// should've been updated
+			got := test.args
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestAllTransactionsRisk(t *testing.T) {
+	tests := []struct {
+		name string
+		args []Transaction
+		want RiskRateResults
+	}{
+		{
+			name: `Should return after sorting LineNumber "medium", "high", "low", "low" `,
+			args: []Transaction{
+				{LineNumber: 3, RiskRate: LOW},
+				{LineNumber: 1, RiskRate: MEDIUM},
+				{LineNumber: 5, RiskRate: LOW},
+				{LineNumber: 2, RiskRate: HIGH},
+			},
+			want: RiskRateResults{RiskRates: []string{"medium", "high", "low", "low"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := allTransactionsRisk(test.args)
+			// should've been updated
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestCheckTransactions(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    TransactionsPerUserMap
+		want    RiskRateResults
+		wantErr error
+	}{
+		{
+			name: "evaluating 8 transactions from 3 users, should return string slice with risk ratings ordered by line number ",
+			args: map[uint]mapset.Set[Transaction]{
+				10: mapset.NewSet([]Transaction{
+					{TransactionId: 12, UserId: 10, Amount: USD(2200000), IdCardUsed: 1, LineNumber: 5}, //high per rule 4
+					{TransactionId: 9, UserId: 10, Amount: USD(10000), IdCardUsed: 1, LineNumber: 2},    // evaluated first, so low (no rule match)
+				}...),
+
+				5: mapset.NewSet([]Transaction{
+					{TransactionId: 13, UserId: 5, Amount: USD(500600), IdCardUsed: 321, LineNumber: 1}, // medium per rule 1
+					{TransactionId: 2, UserId: 5, Amount: USD(10000), IdCardUsed: 121, LineNumber: 3},   // medium per rule 5
+					{TransactionId: 5, UserId: 5, Amount: USD(10000), IdCardUsed: 132, LineNumber: 7},   // high per rule 6
+				}...),
+
+				31: mapset.NewSet([]Transaction{
+					{TransactionId: 4, UserId: 31, Amount: USD(5000000), IdCardUsed: 22, LineNumber: 8}, // high per rules 2, 4 (1 and 5 match, but are lower priority).
+					{TransactionId: 3, UserId: 31, Amount: USD(1200000), IdCardUsed: 21, LineNumber: 4}, // high per rule 2
+					{TransactionId: 11, UserId: 31, Amount: USD(1000), IdCardUsed: 20, LineNumber: 6},   // medium per rules 3, 5
+				}...),
+			},
+			want: RiskRateResults{RiskRates: []string{"medium", "low", "medium", "high", "high", "medium", "high", "high"}},
+		},
+		{
+			name: "a user total overflowing should return the error and no ratings",
+			args: map[uint]mapset.Set[Transaction]{
+				1: mapset.NewSet([]Transaction{
+					{TransactionId: 1, UserId: 1, Amount: USD(math.MaxInt64), IdCardUsed: 1, LineNumber: 1},
+					{TransactionId: 2, UserId: 1, Amount: USD(1), IdCardUsed: 1, LineNumber: 2},
+				}...),
+			},
+			wantErr: ErrMoneyOverflow,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := CheckTransactions(test.args)
+			assert.ErrorIs(t, err, test.wantErr)
+			// should've been updated
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	. "transactionriskassessment/domain"
+)
+
+// DefaultShadowQueueSize is how many batches wait for the shadow engine when Shadow.QueueSize is 0
+const DefaultShadowQueueSize = 100
+
+// ErrShadowQueueFull is reported for the batches the shadow engine had no room for, they are not compared
+var ErrShadowQueueFull = errors.New("shadow queue full")
+
+// ErrShadowStopped is reported for the batches committed once the engine was closed or reconfigured, see Engine.Close
+var ErrShadowStopped = errors.New("shadow stopped")
+
+// Divergence is a transaction the shadow engine rated differently from the primary one
+type Divergence struct {
+	Transaction Transaction `json:"transaction"`
+	PrimaryRisk string      `json:"primary_risk"`
+	ShadowRisk  string      `json:"shadow_risk"`
+}
+
+// ShadowReport is the outcome of assessing one batch with the shadow engine
+type ShadowReport struct {
+	// how many transactions both engines assessed
+	Transactions int
+	Divergences  []Divergence
+	// error of the shadow engine, the batch is not compared when set
+	Err error
+}
+
+// Shadow is a challenger engine assessing every batch alongside the primary one. Its results are
+// only reported, never returned, so a new rule set can be compared with the live one before switching
+type Shadow struct {
+	Engine *Engine
+	// called after each batch the primary engine assessed successfully, from the goroutine running the shadow
+	// engine, or from the primary one for the batches dropped with ErrShadowQueueFull or ErrShadowStopped
+	Report func(ShadowReport)
+	// how many batches can wait for the shadow engine, DefaultShadowQueueSize when 0. Once full, the
+	// batches are dropped rather than slowing the primary engine down
+	QueueSize int
+
+	queue chan shadowBatch
+	// held to read stopped while queueing, and to set it, so no batch is queued once the queue is closed
+	mutex   *sync.RWMutex
+	stopped bool
+}
+
+// a batch the primary engine assessed, waiting for the shadow one
+type shadowBatch struct {
+	ctx          context.Context
+	transactions []Transaction
+	primary      RiskRateResults
+}
+
+// WithShadow makes the engine also assess every batch with shadow.Engine, which should have its own history.
+// The shadow engine runs in its own goroutine, one batch at a time, so it never delays the primary results
+func WithShadow(shadow Shadow) Option {
+	return func(engine *Engine) {
+		engine.shadow = startShadow(&shadow)
+	}
+}
+
+// returns a copy of the shadow settings with a queue of its own, compared in a new goroutine until the copy is stopped
+func startShadow(shadow *Shadow) *Shadow {
+	started := &Shadow{Engine: shadow.Engine, Report: shadow.Report, QueueSize: shadow.QueueSize}
+	if started.QueueSize <= 0 {
+		started.QueueSize = DefaultShadowQueueSize
+	}
+	started.queue = make(chan shadowBatch, started.QueueSize)
+	started.mutex = &sync.RWMutex{}
+	go started.run()
+	return started
+}
+
+// closes the queue, its goroutine ends once the batches already queued are compared
+func (shadow *Shadow) stop() {
+	shadow.mutex.Lock()
+	defer shadow.mutex.Unlock()
+	if !shadow.stopped {
+		shadow.stopped = true
+		close(shadow.queue)
+	}
+}
+
+// queues the batch for the shadow engine. The context keeps its values but is detached from the request, whose
+// end must not cancel the comparison
+func (shadow *Shadow) submit(ctx context.Context, transactions []Transaction, primary RiskRateResults) {
+	// the caller may reuse its slice once the primary results are returned
+	transactions = append([]Transaction(nil), transactions...)
+	if err := shadow.enqueue(shadowBatch{ctx: context.WithoutCancel(ctx), transactions: transactions, primary: primary}); err != nil && shadow.Report != nil {
+		shadow.Report(ShadowReport{Transactions: len(transactions), Err: err})
+	}
+}
+
+// adds the batch to the queue without waiting, failing when it is full or stopped
+func (shadow *Shadow) enqueue(batch shadowBatch) error {
+	shadow.mutex.RLock()
+	defer shadow.mutex.RUnlock()
+	if shadow.stopped {
+		return ErrShadowStopped
+	}
+	select {
+	case shadow.queue <- batch:
+		return nil
+	default:
+		return ErrShadowQueueFull
+	}
+}
+
+// compares the queued batches until the queue is closed
+func (shadow *Shadow) run() {
+	for batch := range shadow.queue {
+		shadow.compare(batch.ctx, batch.transactions, batch.primary)
+	}
+}
+
+// assesses the batch with the shadow engine and reports where it disagrees with the primary results
+func (shadow *Shadow) compare(ctx context.Context, transactions []Transaction, primary RiskRateResults) {
+	report := ShadowReport{Transactions: len(transactions)}
+
+	shadowResults, err := shadow.Engine.Assess(ctx, transactions)
+	if err != nil {
+		report.Err = err
+	} else {
+		for index, transaction := range transactions {
+			if primary.RiskRates[index] != shadowResults.RiskRates[index] {
+				report.Divergences = append(report.Divergences, Divergence{
+					Transaction: transaction,
+					PrimaryRisk: primary.RiskRates[index],
+					ShadowRisk:  shadowResults.RiskRates[index],
+				})
+			}
+		}
+	}
+
+	if shadow.Report != nil {
+		shadow.Report(report)
+	}
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+	. "transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waits for the next report of the shadow engine
+func nextShadowReport(t *testing.T, reports chan ShadowReport) ShadowReport {
+	t.Helper()
+	select {
+	case report := <-reports:
+		return report
+	case <-time.After(time.Second):
+		t.Fatal("no shadow report")
+		return ShadowReport{}
+	}
+}
+
+func TestEngineAssess_WithShadow(t *testing.T) {
+	reports := make(chan ShadowReport, 1)
+	// the challenger flags every amount above 1000 cents as high
+	challenger := New(WithRules(Rule{Name: "small_limit", Evaluate: func(userTransactions []Transaction, _ *Batch) {
+		for index := range userTransactions {
+			if userTransactions[index].Amount.MinorUnits > 1000 {
+				userTransactions[index].RiskRate = greaterRisk(userTransactions[index].RiskRate, HIGH)
+			}
+		}
+	}}))
+	riskEngine := New(WithShadow(Shadow{Engine: challenger, Report: func(report ShadowReport) {
+		reports <- report
+	}}))
+
+	transactions := []Transaction{
+		{TransactionId: 1, UserId: 1, Amount: USD(500), IdCardUsed: 1},
+		{TransactionId: 2, UserId: 2, Amount: USD(2000), IdCardUsed: 2},
+		{TransactionId: 3, UserId: 3, Amount: USD(1100000), IdCardUsed: 3},
+	}
+	// the request ends with the primary results, the shadow engine still compares them
+	ctx, cancel := context.WithCancel(context.Background())
+	got, err := riskEngine.Assess(ctx, transactions)
+	cancel()
+	assert.NoError(t, err)
+	// only the primary result is returned
+	assert.Equal(t, []string{"low", "low", "high"}, got.RiskRates)
+
+	assert.Equal(t, ShadowReport{
+		Transactions: 3,
+		Divergences:  []Divergence{{Transaction: transactions[1], PrimaryRisk: "low", ShadowRisk: "high"}},
+	}, nextShadowReport(t, reports))
+}
+
+func TestEngineAssess_WithShadowQueueFull(t *testing.T) {
+	reports, release := make(chan ShadowReport), make(chan struct{})
+	riskEngine := New(WithShadow(Shadow{Engine: New(), QueueSize: 1, Report: func(report ShadowReport) {
+		reports <- report
+		if report.Err == nil {
+			// holds the shadow engine busy
+			<-release
+		}
+	}}))
+	assess := func(id uint) {
+		t.Helper()
+		_, err := riskEngine.Assess(context.Background(), []Transaction{{TransactionId: id, UserId: 1, Amount: USD(100)}})
+		assert.NoError(t, err)
+	}
+
+	assess(1)
+	assert.NoError(t, nextShadowReport(t, reports).Err)
+	// the first batch is being compared and the second one waits, the third one is dropped
+	assess(2)
+	go assess(3)
+	assert.ErrorIs(t, nextShadowReport(t, reports).Err, ErrShadowQueueFull)
+
+	close(release)
+	assert.NoError(t, nextShadowReport(t, reports).Err)
+}
+
+func TestEngineAssess_WithShadowError(t *testing.T) {
+	reports := make(chan ShadowReport, 1)
+	riskEngine := New(WithShadow(Shadow{Engine: New(), Report: func(report ShadowReport) {
+		reports <- report
+	}}))
+
+	// the shadow is only run once the primary engine succeeded
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := riskEngine.Assess(ctx, []Transaction{{TransactionId: 1, UserId: 1}})
+	assert.ErrorIs(t, err, context.Canceled)
+	select {
+	case report := <-reports:
+		t.Errorf("unexpected shadow report %+v", report)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEngineReconfigure_WithShadow(t *testing.T) {
+	reports := make(chan ShadowReport, 1)
+	riskEngine := New(WithShadow(Shadow{Engine: New(), Report: func(report ShadowReport) {
+		reports <- report
+	}}))
+	transactions := []Transaction{{TransactionId: 1, UserId: 1, Amount: USD(100)}}
+
+	// the reconfigured engine compares its batches, the previous one no longer does
+	reconfigured := riskEngine.Reconfigure(DefaultConfig())
+	_, err := reconfigured.Assess(context.Background(), transactions)
+	assert.NoError(t, err)
+	assert.NoError(t, nextShadowReport(t, reports).Err)
+
+	_, err = riskEngine.Assess(context.Background(), transactions)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, nextShadowReport(t, reports).Err, ErrShadowStopped)
+}
+
+func TestEngineClose(t *testing.T) {
+	reports, release := make(chan ShadowReport), make(chan struct{})
+	riskEngine := New(WithShadow(Shadow{Engine: New(), Report: func(report ShadowReport) {
+		reports <- report
+		if report.Err == nil {
+			// holds the shadow engine busy
+			<-release
+		}
+	}}))
+	assess := func(id uint) {
+		t.Helper()
+		_, err := riskEngine.Assess(context.Background(), []Transaction{{TransactionId: id, UserId: 1, Amount: USD(100)}})
+		assert.NoError(t, err)
+	}
+
+	assess(1)
+	assert.NoError(t, nextShadowReport(t, reports).Err)
+	assess(2)
+	riskEngine.Close()
+	// closing again, or an engine without shadow, does nothing
+	riskEngine.Close()
+	New().Close()
+	go assess(3)
+	assert.ErrorIs(t, nextShadowReport(t, reports).Err, ErrShadowStopped)
+
+	// the batch queued before closing is still compared
+	close(release)
+	assert.NoError(t, nextShadowReport(t, reports).Err)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"transactionriskassessment/client"
+	"transactionriskassessment/feedback"
+
+	"github.com/gin-gonic/gin"
+)
+
+// analysts feedback, kept in memory unless FEEDBACK_LOG is set. Open never fails without a path
+var feedbackStore, _ = feedback.Open("")
+
+// feedbackInput is the body of POST /feedback. User and card are only needed for transactions
+// the server did not assess since it started, the one missing is taken from the assessed transaction
+type feedbackInput struct {
+	TransactionId uint           `json:"transaction_id"`
+	Label         feedback.Label `json:"label"`
+	UserId        uint           `json:"user_id"`
+	CardId        uint           `json:"card_id"`
+	Note          string         `json:"note"`
+}
+
+// PostFeedback labels an assessed transaction of the tenant as fraud, legitimate or chargeback. Fraud and chargebacks
+// raise the risk of later transactions of the same card and user
+func PostFeedback(context *gin.Context) {
+	var input feedbackInput
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := context.BindJSON(&input); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// the parties not sent are those of the assessed transaction
+	userId, cardId := input.UserId, input.CardId
+	if userId == 0 || cardId == 0 {
+		var assessedUserId, assessedCardId uint
+		isKnown := false
+		if history := tenant.engine.History(); history != nil {
+			assessedUserId, assessedCardId, isKnown = history.TransactionParties(input.TransactionId)
+		}
+		if !isKnown {
+			context.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("transaction %d was not assessed, send its user_id and card_id", input.TransactionId)})
+			return
+		}
+		if userId == 0 {
+			userId = assessedUserId
+		}
+		if cardId == 0 {
+			cardId = assessedCardId
+		}
+	}
+	// a label on user or card 0 would match every later transaction missing it
+	if userId == 0 || cardId == 0 {
+		context.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("transaction %d has no user_id or card_id to label", input.TransactionId)})
+		return
+	}
+
+	stored, err := tenant.feedback.Add(feedback.Feedback{
+		TransactionId: input.TransactionId,
+		UserId:        userId,
+		CardId:        cardId,
+		Label:         input.Label,
+		Note:          input.Note,
+	})
+	if errors.Is(err, feedback.ErrInvalidLabel) {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	context.IndentedJSON(http.StatusCreated, stored)
+}
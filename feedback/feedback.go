@@ -0,0 +1,165 @@
+// Package feedback keeps the labels analysts give to assessed transactions once their outcome is known,
+// so confirmed fraud raises the risk of the cards and users involved in later assessments
+package feedback
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Label is the known outcome of a transaction
+type Label string
+
+const (
+	Fraud      Label = "fraud"
+	Legitimate Label = "legitimate"
+	Chargeback Label = "chargeback"
+)
+
+// ErrInvalidLabel is returned by Add for labels other than the known ones
+var ErrInvalidLabel = errors.New(`label must be "fraud", "legitimate" or "chargeback"`)
+
+// IsFraud tells whether the label confirms fraud. Chargebacks count as fraud, the cardholder disputed the transaction
+func (label Label) IsFraud() bool {
+	return label == Fraud || label == Chargeback
+}
+
+func (label Label) isValid() bool {
+	return label == Fraud || label == Legitimate || label == Chargeback
+}
+
+// Feedback is the label of one transaction
+type Feedback struct {
+	TransactionId uint      `json:"transaction_id"`
+	UserId        uint      `json:"user_id"`
+	CardId        uint      `json:"card_id"`
+	Label         Label     `json:"label"`
+	Note          string    `json:"note,omitempty"`
+	Time          time.Time `json:"time"`
+}
+
+// Store keeps the latest label of each transaction, in memory and, when it has a path, appended to a local file.
+// It is safe for concurrent use
+type Store struct {
+	mutex sync.RWMutex
+	// nil for stores kept only in memory
+	file   *os.File
+	labels map[uint]Feedback
+	// how many transactions currently labelled as fraud involve each card and user
+	fraudCards map[uint]int
+	fraudUsers map[uint]int
+}
+
+// Open loads the feedback file at path, creating it when missing. An empty path keeps the feedback in memory only
+func Open(path string) (*Store, error) {
+	store := &Store{labels: make(map[uint]Feedback), fraudCards: make(map[uint]int), fraudUsers: make(map[uint]int)}
+	if path == "" {
+		return store, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var feedback Feedback
+		if err := json.Unmarshal(scanner.Bytes(), &feedback); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		store.apply(feedback)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	store.file = file
+	return store, nil
+}
+
+// replaces the label of the transaction, updating the fraud counts
+func (store *Store) apply(feedback Feedback) {
+	if previous, isLabelled := store.labels[feedback.TransactionId]; isLabelled && previous.Label.IsFraud() {
+		decrement(store.fraudCards, previous.CardId)
+		decrement(store.fraudUsers, previous.UserId)
+	}
+	if feedback.Label.IsFraud() {
+		store.fraudCards[feedback.CardId]++
+		store.fraudUsers[feedback.UserId]++
+	}
+	store.labels[feedback.TransactionId] = feedback
+}
+
+func decrement(counts map[uint]int, id uint) {
+	if counts[id] <= 1 {
+		delete(counts, id)
+		return
+	}
+	counts[id]--
+}
+
+// Add records the label, replacing any previous one of the same transaction, and returns it.
+// Time is set to now when zero
+func (store *Store) Add(feedback Feedback) (Feedback, error) {
+	if !feedback.Label.isValid() {
+		return feedback, ErrInvalidLabel
+	}
+	if feedback.Time.IsZero() {
+		feedback.Time = time.Now().UTC()
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.file != nil {
+		line, err := json.Marshal(feedback)
+		if err != nil {
+			return feedback, err
+		}
+		if _, err := store.file.Write(append(line, '\n')); err != nil {
+			return feedback, err
+		}
+	}
+	store.apply(feedback)
+	return feedback, nil
+}
+
+// Get returns the latest label of the transaction
+func (store *Store) Get(transactionId uint) (Feedback, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	feedback, isLabelled := store.labels[transactionId]
+	return feedback, isLabelled
+}
+
+// IsFraudCard tells whether a transaction made with the card is currently labelled as fraud
+func (store *Store) IsFraudCard(cardId uint) bool {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.fraudCards[cardId] > 0
+}
+
+// IsFraudUser tells whether a transaction made by the user is currently labelled as fraud
+func (store *Store) IsFraudUser(userId uint) bool {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	return store.fraudUsers[userId] > 0
+}
+
+// Close closes the file, the store cannot be used afterwards
+func (store *Store) Close() error {
+	if store.file == nil {
+		return nil
+	}
+	return store.file.Close()
+}
@@ -0,0 +1,64 @@
+package feedback
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreAdd(t *testing.T) {
+	store, err := Open("")
+	assert.NoError(t, err)
+
+	_, err = store.Add(Feedback{TransactionId: 1, UserId: 10, CardId: 20, Label: Fraud})
+	assert.NoError(t, err)
+	_, err = store.Add(Feedback{TransactionId: 2, UserId: 11, CardId: 20, Label: Chargeback})
+	assert.NoError(t, err)
+	_, err = store.Add(Feedback{TransactionId: 3, UserId: 12, CardId: 21, Label: Legitimate})
+	assert.NoError(t, err)
+
+	assert.True(t, store.IsFraudCard(20))
+	assert.True(t, store.IsFraudUser(10))
+	assert.True(t, store.IsFraudUser(11))
+	assert.False(t, store.IsFraudCard(21))
+	assert.False(t, store.IsFraudUser(12))
+
+	// relabelling a transaction as legitimate clears it, the card stays flagged by the other one
+	got, err := store.Add(Feedback{TransactionId: 1, UserId: 10, CardId: 20, Label: Legitimate, Note: "false positive"})
+	assert.NoError(t, err)
+	assert.False(t, got.Time.IsZero())
+	assert.False(t, store.IsFraudUser(10))
+	assert.True(t, store.IsFraudCard(20))
+
+	stored, isLabelled := store.Get(1)
+	assert.True(t, isLabelled)
+	assert.Equal(t, got, stored)
+
+	_, err = store.Add(Feedback{TransactionId: 4, Label: "unknown"})
+	assert.ErrorIs(t, err, ErrInvalidLabel)
+}
+
+func TestOpen_ReloadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feedback.log")
+	store, err := Open(path)
+	assert.NoError(t, err)
+	_, err = store.Add(Feedback{TransactionId: 1, UserId: 10, CardId: 20, Label: Fraud})
+	assert.NoError(t, err)
+	_, err = store.Add(Feedback{TransactionId: 2, UserId: 11, CardId: 21, Label: Fraud})
+	assert.NoError(t, err)
+	_, err = store.Add(Feedback{TransactionId: 2, UserId: 11, CardId: 21, Label: Legitimate})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	reopened, err := Open(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.True(t, reopened.IsFraudCard(20))
+	assert.False(t, reopened.IsFraudCard(21))
+
+	assert.NoError(t, os.WriteFile(path, []byte("{\n"), 0o600))
+	_, err = Open(path)
+	assert.ErrorContains(t, err, "feedback.log:1")
+}
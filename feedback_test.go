@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+	"transactionriskassessment/feedback"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// replaces the feedback and the engine, with a history, for the duration of the test
+func useTestFeedback(t *testing.T) {
+	previousStore, previousEngine := feedbackStore, riskEngine
+	feedbackStore, _ = feedback.Open("")
+	riskEngine = engine.New(engine.WithHistory(engine.NewHistory()), engine.WithFraudLabels(feedbackStore))
+	t.Cleanup(func() { feedbackStore, riskEngine = previousStore, previousEngine })
+}
+
+// returns the ratings of a POST /check_transactions response
+func decodeRatings(t *testing.T, recorder *httptest.ResponseRecorder) []string {
+	var results domain.RiskRateResults
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+		t.Fatalf("decoding %s: %v", recorder.Body.String(), err)
+	}
+	return results.RiskRates
+}
+
+func TestPostFeedback(t *testing.T) {
+	useTestFeedback(t)
+	document := loadOpenAPIDocument(t)
+
+	_, err := riskEngine.Assess(context.Background(), []domain.Transaction{
+		{TransactionId: 7, UserId: 1, IdCardUsed: 3},
+		{TransactionId: 11, UserId: 5, IdCardUsed: 6},
+		// without card
+		{TransactionId: 12, UserId: 6},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		payload string
+		status  int
+	}{
+		{name: "assessed transaction", payload: `{"transaction_id": 7, "label": "fraud"}`, status: http.StatusCreated},
+		{name: "parties given", payload: `{"transaction_id": 8, "label": "chargeback", "user_id": 2, "card_id": 4, "note": "disputed"}`, status: http.StatusCreated},
+		{name: "only the user given", payload: `{"transaction_id": 11, "label": "fraud", "user_id": 5}`, status: http.StatusCreated},
+		{name: "unknown transaction", payload: `{"transaction_id": 9, "label": "fraud"}`, status: http.StatusNotFound},
+		{name: "unknown transaction with only the user", payload: `{"transaction_id": 9, "label": "fraud", "user_id": 3}`, status: http.StatusNotFound},
+		{name: "unknown transaction with only the card", payload: `{"transaction_id": 9, "label": "fraud", "card_id": 3}`, status: http.StatusNotFound},
+		{name: "assessed transaction without card", payload: `{"transaction_id": 12, "label": "fraud"}`, status: http.StatusBadRequest},
+		{name: "unknown label", payload: `{"transaction_id": 7, "label": "suspicious"}`, status: http.StatusBadRequest},
+		{name: "invalid body", payload: `{"transaction_id": `, status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveTestRequest("POST", "/feedback", test.payload, nil)
+			assert.Equal(t, test.status, recorder.Code)
+
+			var body any
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			assert.Empty(t, validateSchema(document, responseSchema(t, document, "POST", "/feedback", recorder.Code), body, "response"))
+		})
+	}
+
+	stored, isLabelled := feedbackStore.Get(7)
+	assert.True(t, isLabelled)
+	assert.Equal(t, feedback.Feedback{TransactionId: 7, UserId: 1, CardId: 3, Label: feedback.Fraud, Time: stored.Time}, stored)
+	// the card missing is the one assessed
+	stored, _ = feedbackStore.Get(11)
+	assert.Equal(t, uint(6), stored.CardId)
+	_, isLabelled = feedbackStore.Get(12)
+	assert.False(t, isLabelled)
+}
+
+func TestPostFeedback_RaisesLaterRisk(t *testing.T) {
+	useTestFeedback(t)
+	payload := `{"transactions": [{"id": 10, "user_id": 5, "amount_us_cents": 100, "card_id": 3}, {"id": 11, "user_id": 1, "amount_us_cents": 100, "card_id": 4}]}`
+
+	recorder := serveTestRequest("POST", "/check_transactions", payload, nil)
+	assert.Equal(t, []string{"low", "low"}, decodeRatings(t, recorder))
+
+	// card 3 was used in fraud by user 1
+	recorder = serveTestRequest("POST", "/feedback", `{"transaction_id": 7, "label": "fraud", "user_id": 1, "card_id": 3}`, nil)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	recorder = serveTestRequest("POST", "/check_transactions", payload, nil)
+	assert.Equal(t, []string{"high", "medium"}, decodeRatings(t, recorder))
+}
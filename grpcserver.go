@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"transactionriskassessment/audit"
+	"transactionriskassessment/domain"
+	"transactionriskassessment/riskpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// riskAssessmentServer implements the gRPC service on top of the same engine used by AssessTransactions
+type riskAssessmentServer struct {
+	riskpb.UnimplementedRiskAssessmentServer
+}
+
+// CheckTransactions assesses one batch, as POST /check_transactions does
+func (riskAssessmentServer) CheckTransactions(ctx context.Context, input *riskpb.TransactionsInput) (*riskpb.RiskRateResults, error) {
+	tenant, err := grpcTenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	requestId := grpcRequestId(ctx)
+	if err := grpc.SetHeader(ctx, metadata.Pairs(requestIdMetadata, requestId)); err != nil {
+		return nil, err
+	}
+
+	transactions, err := grpcBatch(input)
+	if err != nil {
+		return nil, err
+	}
+	results, err := assessAndRecord(ctx, tenant, requestId, grpcClientIdentity(ctx), transactions)
+	if err != nil {
+		return nil, grpcAssessmentError(err)
+	}
+	return toProtoResults(results), nil
+}
+
+// StreamTransactions assesses each batch as it arrives, sending its results before reading the next one.
+// Each batch is its own assessment, recorded as the stream request id followed by "-" and the batch number
+func (riskAssessmentServer) StreamTransactions(stream riskpb.RiskAssessment_StreamTransactionsServer) error {
+	tenant, err := grpcTenant(stream.Context())
+	if err != nil {
+		return err
+	}
+	streamId := grpcRequestId(stream.Context())
+	if err := stream.SetHeader(metadata.Pairs(requestIdMetadata, streamId)); err != nil {
+		return err
+	}
+	clientId := grpcClientIdentity(stream.Context())
+
+	for batchNumber := 1; ; batchNumber++ {
+		input, err := stream.Recv()
+		// client closed its side of the stream, nothing left to assess
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		transactions, err := grpcBatch(input)
+		if err != nil {
+			return err
+		}
+		requestId := fmt.Sprintf("%s-%d", streamId, batchNumber)
+		results, err := assessAndRecord(stream.Context(), tenant, requestId, clientId, transactions)
+		if err != nil {
+			return grpcAssessmentError(err)
+		}
+		if err := stream.Send(toProtoResults(results)); err != nil {
+			return err
+		}
+	}
+}
+
+// metadata keys matching the HTTP headers, gRPC lowercases them
+const (
+	requestIdMetadata = "x-request-id"
+	apiKeyMetadata    = "x-api-key"
+	tenantMetadata    = "x-tenant-id"
+)
+
+// returns the request id sent by the client in the metadata, or a new one
+func grpcRequestId(ctx context.Context) string {
+	if requestId := firstMetadataValue(ctx, requestIdMetadata); requestId != "" {
+		return requestId
+	}
+	return newRequestId()
+}
+
+// returns the first value of the metadata key, empty when missing
+func firstMetadataValue(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// returns the tenant named in the metadata or owning the API key, as the HTTP server does
+func grpcTenant(ctx context.Context) (*tenant, error) {
+	tenant, err := resolveTenant(firstMetadataValue(ctx, tenantMetadata), firstMetadataValue(ctx, apiKeyMetadata))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return tenant, nil
+}
+
+// identifies the client by its API key, as the HTTP server does, or by its address
+func grpcClientIdentity(ctx context.Context) string {
+	var address string
+	if caller, ok := peer.FromContext(ctx); ok {
+		address = caller.Addr.String()
+	}
+	return clientIdentity(firstMetadataValue(ctx, apiKeyMetadata), address)
+}
+
+// converts an assessment error to its gRPC status, as assessmentErrorStatus does for HTTP
+func grpcAssessmentError(err error) error {
+	if errors.Is(err, audit.ErrDuplicateRequest) {
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	if errors.Is(err, domain.ErrMoneyOverflow) || errors.Is(err, domain.ErrCurrencyMismatch) || errors.Is(err, domain.ErrInvalidTransaction) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// converts the batch to its domain transactions, refusing it with ResourceExhausted when it goes past the
+// transactions or users limits of batchLimits, as POST /check_transactions does with 413
+func grpcBatch(input *riskpb.TransactionsInput) ([]domain.Transaction, error) {
+	transactions := toDomainTransactions(input.GetTransactions())
+	if err := batchLimits.checkBatch(transactions); err != nil {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return transactions, nil
+}
+
+// converts the results to their protobuf message
+func toProtoResults(results domain.RiskRateResults) *riskpb.RiskRateResults {
+	actions := make([]string, len(results.Actions))
+	for index, action := range results.Actions {
+		actions[index] = string(action)
+	}
+	return &riskpb.RiskRateResults{RiskRatings: results.RiskRates, Actions: actions, RuleSetVersion: results.RuleSetVersion}
+}
+
+// converts the protobuf messages to the domain representation used by the risk rules
+func toDomainTransactions(messages []*riskpb.Transaction) []domain.Transaction {
+	transactions := make([]domain.Transaction, 0, len(messages))
+	for _, message := range messages {
+		transaction := domain.Transaction{
+			TransactionId:        uint(message.GetId()),
+			UserId:               uint(message.GetUserId()),
+			Amount:               domain.USD(message.GetAmountUsCents()),
+			IdCardUsed:           uint(message.GetCardId()),
+			MerchantId:           uint(message.GetMerchantId()),
+			MerchantCategoryCode: message.GetMcc(),
+			Country:              message.GetCountry(),
+			CardCountry:          message.GetCardCountry(),
+			Latitude:             message.Latitude,
+			Longitude:            message.Longitude,
+			DeviceId:             message.GetDeviceId(),
+			IPAddress:            message.GetIpAddress(),
+			UserAgent:            message.GetUserAgent(),
+			Type:                 domain.TransactionType(message.GetType()),
+			ReferenceId:          uint(message.GetReferenceId()),
+		}
+		if message.Timestamp != nil {
+			timestamp := message.GetTimestamp().AsTime()
+			transaction.Timestamp = &timestamp
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions
+}
+
+// newGRPCServer creates the gRPC server with the risk assessment service registered
+func newGRPCServer() *grpc.Server {
+	server := grpc.NewServer()
+	riskpb.RegisterRiskAssessmentServer(server, riskAssessmentServer{})
+	return server
+}
+
+// runGRPCServer listens on address and serves gRPC requests until the listener fails
+func runGRPCServer(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return newGRPCServer().Serve(listener)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"transactionriskassessment/riskpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+/** Mock for test cases, same transactions as the README example */
+var grpcInputMock = &riskpb.TransactionsInput{
+	Transactions: []*riskpb.Transaction{
+		{Id: 1, UserId: 1, AmountUsCents: 200000, CardId: 1},
+		{Id: 2, UserId: 1, AmountUsCents: 600000, CardId: 1},
+		{Id: 3, UserId: 1, AmountUsCents: 1100000, CardId: 1},
+		{Id: 4, UserId: 2, AmountUsCents: 100000, CardId: 2},
+		{Id: 5, UserId: 2, AmountUsCents: 100000, CardId: 3},
+		{Id: 6, UserId: 2, AmountUsCents: 100000, CardId: 4},
+	},
+}
+
+// starts the gRPC server over an in-memory listener and returns a client connected to it
+func newTestGRPCClient(t *testing.T) riskpb.RiskAssessmentClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := newGRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	connection, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { connection.Close() })
+
+	return riskpb.NewRiskAssessmentClient(connection)
+}
+
+func TestGRPCCheckTransactions(t *testing.T) {
+	client := newTestGRPCClient(t)
+
+	tests := []struct {
+		name        string
+		input       *riskpb.TransactionsInput
+		want        []string
+		wantActions []string
+	}{
+		{
+			name:        "should return the same ratings and actions as the HTTP API",
+			input:       grpcInputMock,
+			want:        []string{"low", "medium", "high", "low", "medium", "high"},
+			wantActions: []string{"approve", "hold_for_review", "decline", "approve", "hold_for_review", "decline"},
+		},
+		{
+			name:  "should return no ratings for an empty batch",
+			input: &riskpb.TransactionsInput{},
+			want:  nil,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := client.CheckTransactions(context.Background(), test.input)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got.GetRiskRatings())
+			assert.Equal(t, test.wantActions, got.GetActions())
+		})
+	}
+}
+
+func TestGRPCStreamTransactions(t *testing.T) {
+	client := newTestGRPCClient(t)
+
+	stream, err := client.StreamTransactions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each batch is assessed on its own, so user 1 totals do not carry over to the second one
+	batches := []*riskpb.TransactionsInput{
+		grpcInputMock,
+		{Transactions: []*riskpb.Transaction{{Id: 7, UserId: 1, AmountUsCents: 100, CardId: 1}}},
+	}
+	want := [][]string{
+		{"low", "medium", "high", "low", "medium", "high"},
+		{"low"},
+	}
+
+	for index, batch := range batches {
+		assert.NoError(t, stream.Send(batch))
+		got, err := stream.Recv()
+		assert.NoError(t, err)
+		assert.Equal(t, want[index], got.GetRiskRatings())
+	}
+
+	assert.NoError(t, stream.CloseSend())
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestGRPCLimits(t *testing.T) {
+	setTestLimits(t, requestLimits{MaxTransactions: 5, MaxUsers: 1})
+	client := newTestGRPCClient(t)
+
+	// the mock has six transactions of two users
+	_, err := client.CheckTransactions(context.Background(), grpcInputMock)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.ErrorContains(t, err, "request exceeds max_transactions of 5")
+
+	twoUsers := &riskpb.TransactionsInput{Transactions: []*riskpb.Transaction{{Id: 1, UserId: 1}, {Id: 2, UserId: 2}}}
+	_, err = client.CheckTransactions(context.Background(), twoUsers)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.ErrorContains(t, err, "request exceeds max_users of 1")
+
+	// a stream answers the batches within the limits and ends on the first one past them
+	stream, err := client.StreamTransactions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, stream.Send(&riskpb.TransactionsInput{Transactions: []*riskpb.Transaction{{Id: 3, UserId: 1, AmountUsCents: 100}}}))
+	got, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, got.GetRiskRatings())
+	assert.NoError(t, stream.Send(twoUsers))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+	"transactionriskassessment/client"
+	"transactionriskassessment/idempotency"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// requests with this header are answered only once, retries get the first response
+	idempotencyKeyHeader = "Idempotency-Key"
+	// set to "true" on replayed responses
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// how long responses are kept unless IDEMPOTENCY_TTL says otherwise
+	defaultIdempotencyTTL = 24 * time.Hour
+)
+
+// responses given to idempotency keys, nil when IDEMPOTENCY_TTL is 0
+var idempotencyStore *idempotency.Store
+
+// keeps the bytes written to the response, so they can be replayed
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (writer *recordingWriter) Write(data []byte) (int, error) {
+	writer.body.Write(data)
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *recordingWriter) WriteString(data string) (int, error) {
+	writer.body.WriteString(data)
+	return writer.ResponseWriter.WriteString(data)
+}
+
+// idempotent answers retried requests, those with an Idempotency-Key already seen, with the first response to the key
+// instead of processing them again, so spending is not counted twice. Keys are scoped by tenant, and reusing a key
+// with a different body is a conflict. Server errors are not kept, the retry is processed
+func idempotent(context *gin.Context) {
+	key := context.GetHeader(idempotencyKeyHeader)
+	if key == "" || idempotencyStore == nil {
+		context.Next()
+		return
+	}
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		// the handler answers it
+		context.Next()
+		return
+	}
+
+	// hashed as it is read, through the size limit set by limitRequestBody, so a body past it is refused unread
+	bodyHash := sha256.New()
+	body, err := io.ReadAll(io.TeeReader(context.Request.Body, bodyHash))
+	if err != nil {
+		respondBodyError(context, err)
+		return
+	}
+	context.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	storeKey := tenant.id + "/" + key
+	response, err := idempotencyStore.Begin(storeKey, [sha256.Size]byte(bodyHash.Sum(nil)))
+	if errors.Is(err, idempotency.ErrKeyReused) || errors.Is(err, idempotency.ErrInProgress) {
+		context.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if response != nil {
+		context.Header(requestIdHeader, response.RequestId)
+		context.Header(idempotentReplayedHeader, "true")
+		context.Data(response.Status, "application/json; charset=utf-8", response.Body)
+		context.Abort()
+		return
+	}
+
+	// abandoned unless answered, also when the handler panics, so retries are not refused as in progress
+	isFinished := false
+	defer func() {
+		if !isFinished {
+			idempotencyStore.Abandon(storeKey)
+		}
+	}()
+
+	recorder := &recordingWriter{ResponseWriter: context.Writer}
+	context.Writer = recorder
+	context.Next()
+
+	if recorder.Status() >= http.StatusInternalServerError {
+		return
+	}
+	idempotencyStore.Finish(storeKey, idempotency.Response{
+		Status:    recorder.Status(),
+		RequestId: recorder.Header().Get(requestIdHeader),
+		Body:      recorder.body.Bytes(),
+	})
+	isFinished = true
+}
@@ -0,0 +1,125 @@
+// Package idempotency remembers the response given to each idempotency key for a while, so a retried request
+// is answered with the same response instead of being processed again
+package idempotency
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrKeyReused is returned by Begin when the key was first used with a different request body
+	ErrKeyReused = errors.New("idempotency key already used with a different request body")
+	// ErrInProgress is returned by Begin when the first request with the key has not been answered yet
+	ErrInProgress = errors.New("a request with the same idempotency key is still being processed")
+)
+
+// Response is what was answered to the first request with a key
+type Response struct {
+	Status    int
+	RequestId string
+	Body      []byte
+}
+
+type entry struct {
+	bodyHash [sha256.Size]byte
+	// nil while the first request is being processed
+	response *Response
+	// a request never answered, nor abandoned, stops blocking its key after the same time
+	expiresAt time.Time
+}
+
+// keys in the order they expire, all entries are kept for the same time
+type expiry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// Store holds the responses in memory until they expire. It is safe for concurrent use
+type Store struct {
+	mutex    sync.Mutex
+	ttl      time.Duration
+	entries  map[string]*entry
+	expiries []expiry
+	// replaced by tests
+	now func() time.Time
+}
+
+// New creates a store keeping each response for ttl after it was given
+func New(ttl time.Duration) *Store {
+	return &Store{ttl: ttl, entries: make(map[string]*entry), now: time.Now}
+}
+
+// TTL returns how long responses are kept
+func (store *Store) TTL() time.Duration {
+	return store.ttl
+}
+
+// Begin starts the request with the key and the SHA-256 hash of its body. It returns the response to replay when
+// the key was already answered for the same body, nil when the request must be processed, in which case Finish or
+// Abandon must follow
+func (store *Store) Begin(key string, bodyHash [sha256.Size]byte) (*Response, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.removeExpired()
+
+	existing, isKnown := store.entries[key]
+	if !isKnown {
+		store.entries[key] = &entry{bodyHash: bodyHash}
+		store.expireLater(key, store.entries[key])
+		return nil, nil
+	}
+	if existing.bodyHash != bodyHash {
+		return nil, ErrKeyReused
+	}
+	if existing.response == nil {
+		return nil, ErrInProgress
+	}
+	return existing.response, nil
+}
+
+// Finish keeps the response given to the request started with the key
+func (store *Store) Finish(key string, response Response) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	existing, isKnown := store.entries[key]
+	if !isKnown || existing.response != nil {
+		return
+	}
+	existing.response = &response
+	store.expireLater(key, existing)
+}
+
+// sets the entry to expire after the store ttl from now. Expiries set before for the key are then ignored
+func (store *Store) expireLater(key string, existing *entry) {
+	existing.expiresAt = store.now().Add(store.ttl)
+	store.expiries = append(store.expiries, expiry{key: key, expiresAt: existing.expiresAt})
+}
+
+// Abandon forgets the request started with the key, so a retry processes it again
+func (store *Store) Abandon(key string) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if existing, isKnown := store.entries[key]; isKnown && existing.response == nil {
+		delete(store.entries, key)
+	}
+}
+
+// removes the entries past their time, the oldest are first in expiries
+func (store *Store) removeExpired() {
+	now := store.now()
+	expired := 0
+	for expired < len(store.expiries) && !store.expiries[expired].expiresAt.After(now) {
+		key := store.expiries[expired].key
+		// the key may have expired before and be in use again, or have been answered since it was begun
+		if existing, isKnown := store.entries[key]; isKnown && existing.expiresAt.Equal(store.expiries[expired].expiresAt) {
+			delete(store.entries, key)
+		}
+		expired++
+	}
+	store.expiries = store.expiries[expired:]
+}
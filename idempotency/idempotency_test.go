@@ -0,0 +1,105 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// returns a store whose clock is moved by the returned function
+func newTestStore(ttl time.Duration) (*Store, func(time.Duration)) {
+	store := New(ttl)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	return store, func(elapsed time.Duration) { now = now.Add(elapsed) }
+}
+
+// returns the hash of the request body
+func bodyHash(body string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(body))
+}
+
+func TestStore_Replay(t *testing.T) {
+	store, _ := newTestStore(time.Hour)
+
+	response, err := store.Begin("key", bodyHash("body"))
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+
+	// the first request is still being processed
+	_, err = store.Begin("key", bodyHash("body"))
+	assert.ErrorIs(t, err, ErrInProgress)
+
+	store.Finish("key", Response{Status: http.StatusOK, RequestId: "request-1", Body: []byte("ratings")})
+	response, err = store.Begin("key", bodyHash("body"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Response{Status: http.StatusOK, RequestId: "request-1", Body: []byte("ratings")}, response)
+
+	_, err = store.Begin("key", bodyHash("another body"))
+	assert.ErrorIs(t, err, ErrKeyReused)
+
+	// other keys are independent
+	response, err = store.Begin("other key", bodyHash("another body"))
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+}
+
+func TestStore_Expiry(t *testing.T) {
+	store, elapse := newTestStore(time.Hour)
+
+	store.Begin("key", bodyHash("body"))
+	store.Finish("key", Response{Status: http.StatusOK})
+
+	elapse(59 * time.Minute)
+	response, _ := store.Begin("key", bodyHash("body"))
+	assert.NotNil(t, response)
+
+	// once expired the key may be used again, even with another body
+	elapse(time.Minute)
+	response, err := store.Begin("key", bodyHash("another body"))
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+	assert.Len(t, store.expiries, 1)
+
+	// the new response lives its own time
+	store.Finish("key", Response{Status: http.StatusBadRequest})
+	elapse(30 * time.Minute)
+	response, _ = store.Begin("key", bodyHash("another body"))
+	assert.Equal(t, http.StatusBadRequest, response.Status)
+}
+
+func TestStore_Abandon(t *testing.T) {
+	store, _ := newTestStore(time.Hour)
+
+	store.Begin("key", bodyHash("body"))
+	store.Abandon("key")
+
+	// nothing was answered, so the retry is processed
+	response, err := store.Begin("key", bodyHash("another body"))
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+
+	// answered keys are not abandoned
+	store.Finish("key", Response{Status: http.StatusOK})
+	store.Abandon("key")
+	response, _ = store.Begin("key", bodyHash("another body"))
+	assert.NotNil(t, response)
+}
+
+func TestStore_InProgressExpiry(t *testing.T) {
+	store, elapse := newTestStore(time.Hour)
+
+	// never answered nor abandoned, e.g. the server stopped midway
+	store.Begin("key", bodyHash("body"))
+	elapse(59 * time.Minute)
+	_, err := store.Begin("key", bodyHash("body"))
+	assert.ErrorIs(t, err, ErrInProgress)
+
+	elapse(time.Minute)
+	response, err := store.Begin("key", bodyHash("body"))
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+}
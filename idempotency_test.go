@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"transactionriskassessment/idempotency"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// keeps the responses to idempotency keys for the test, the default engine is replaced by one with history
+func enableTestIdempotency(t *testing.T) {
+	useTestFeedback(t)
+	previousStore := idempotencyStore
+	idempotencyStore = idempotency.New(time.Hour)
+	t.Cleanup(func() { idempotencyStore = previousStore })
+}
+
+func TestAssessTransactions_IdempotencyKey(t *testing.T) {
+	enableTestIdempotency(t)
+	document := loadOpenAPIDocument(t)
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 600000, "card_id": 1}]}`
+	headers := map[string]string{idempotencyKeyHeader: "retry-1"}
+
+	first := serveTestRequest("POST", "/check_transactions", payload, headers)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+
+	retry := serveTestRequest("POST", "/check_transactions", payload, headers)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, first.Header().Get(requestIdHeader), retry.Header().Get(requestIdHeader))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	// the spending was counted once
+	assert.Len(t, riskEngine.History().UserAmounts(1), 1)
+
+	conflict := serveTestRequest("POST", "/check_transactions", `{"transactions": []}`, headers)
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+	assert.Contains(t, conflict.Body.String(), "different request body")
+	var body any
+	assert.NoError(t, json.Unmarshal(conflict.Body.Bytes(), &body))
+	assert.Empty(t, validateSchema(document, responseSchema(t, document, "POST", "/check_transactions", conflict.Code), body, "response"))
+
+	// without the header every request is processed
+	serveTestRequest("POST", "/check_transactions", payload, nil)
+	assert.Len(t, riskEngine.History().UserAmounts(1), 2)
+}
+
+func TestAssessTransactions_IdempotencyKeyPerTenant(t *testing.T) {
+	enableTestIdempotency(t)
+	enableTestTenants(t)
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 100, "card_id": 1}]}`
+
+	serveTestRequest("POST", "/check_transactions", payload, map[string]string{idempotencyKeyHeader: "shared"})
+	// the same key from another tenant is another request
+	recorder := serveTestRequest("POST", "/check_transactions", `{"transactions": []}`, map[string]string{idempotencyKeyHeader: "shared", tenantHeader: "loans"})
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Empty(t, recorder.Header().Get(idempotentReplayedHeader))
+}
+
+func TestAssessTransactions_IdempotencyKeyKeepsClientErrors(t *testing.T) {
+	enableTestIdempotency(t)
+	headers := map[string]string{idempotencyKeyHeader: "invalid"}
+
+	first := serveTestRequest("POST", "/check_transactions", `{"transactions": [`, headers)
+	assert.Equal(t, http.StatusBadRequest, first.Code)
+
+	// the same body is answered the same, without being decoded again
+	retry := serveTestRequest("POST", "/check_transactions", `{"transactions": [`, headers)
+	assert.Equal(t, http.StatusBadRequest, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(idempotentReplayedHeader))
+}
+
+func TestIdempotent_HandlerPanic(t *testing.T) {
+	enableTestIdempotency(t)
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.POST("/panic", idempotent, func(*gin.Context) { panic("handler failed") })
+
+	// the key is not left in progress, the retry is processed again
+	for attempt := 1; attempt <= 2; attempt++ {
+		request, _ := http.NewRequest("POST", "/panic", strings.NewReader(`{}`))
+		request.Header.Set(idempotencyKeyHeader, "panic")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code, "attempt %d", attempt)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"transactionriskassessment/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestLimits bound what a single POST /check_transactions may ask for, 0 disables each limit
+type requestLimits struct {
+	MaxBodyBytes    int64 `json:"max_body_bytes"`
+	MaxTransactions int64 `json:"max_transactions"`
+	MaxUsers        int64 `json:"max_users"`
+}
+
+// limits of every request, set by MAX_BODY_BYTES, MAX_TRANSACTIONS and MAX_USERS
+var batchLimits = requestLimits{MaxBodyBytes: 10 << 20, MaxTransactions: 10000, MaxUsers: 1000}
+
+// limitError tells which limit a request exceeded
+type limitError struct {
+	Limit string `json:"limit"`
+	Max   int64  `json:"max"`
+}
+
+func (err limitError) Error() string {
+	return fmt.Sprintf("request exceeds %s of %d", err.Limit, err.Max)
+}
+
+// reads the limits from the environment, keeping the current ones for the variables unset
+func loadRequestLimits() (requestLimits, error) {
+	limits := batchLimits
+	for key, limit := range map[string]*int64{
+		"MAX_BODY_BYTES":   &limits.MaxBodyBytes,
+		"MAX_TRANSACTIONS": &limits.MaxTransactions,
+		"MAX_USERS":        &limits.MaxUsers,
+	} {
+		value, isSet := os.LookupEnv(key)
+		if !isSet || value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			return limits, fmt.Errorf("%s must be a number of at least 0, got %q", key, value)
+		}
+		*limit = parsed
+	}
+	return limits, nil
+}
+
+// limitRequestBody stops reading the body once it goes past the byte limit, so nothing reads it whole first
+func limitRequestBody(context *gin.Context) {
+	if batchLimits.MaxBodyBytes > 0 {
+		context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, batchLimits.MaxBodyBytes)
+	}
+	context.Next()
+}
+
+// answers a body that could not be read or decoded, 413 naming the limit when one was exceeded
+func respondBodyError(context *gin.Context, err error) {
+	var bodyLimit *http.MaxBytesError
+	if errors.As(err, &bodyLimit) {
+		err = limitError{Limit: "max_body_bytes", Max: bodyLimit.Limit}
+	}
+	var exceeded limitError
+	if errors.As(err, &exceeded) {
+		context.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": exceeded.Error(), "limit": exceeded.Limit, "max": exceeded.Max})
+		return
+	}
+	context.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// decodeTransactionsInput decodes the body one transaction at a time, failing with a limitError as soon as there are
+// more transactions or distinct users than the limits allow, instead of decoding the whole batch first
+func decodeTransactionsInput(body io.Reader, limits requestLimits) (domain.TransactionsInput, error) {
+	var input domain.TransactionsInput
+	decoder := json.NewDecoder(body)
+	if err := expectDelimiter(decoder, '{'); err != nil {
+		return input, err
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return input, err
+		}
+		// field names are matched the way encoding/json does, ignoring case
+		if name, _ := token.(string); !strings.EqualFold(name, "transactions") {
+			var ignored json.RawMessage
+			if err := decoder.Decode(&ignored); err != nil {
+				return input, err
+			}
+			continue
+		}
+		if input.InputTransactions, err = decodeTransactions(decoder, limits); err != nil {
+			return input, err
+		}
+	}
+	return input, expectDelimiter(decoder, '}')
+}
+
+// decodes the transactions array, or null, the decoder is at
+func decodeTransactions(decoder *json.Decoder, limits requestLimits) ([]domain.Transaction, error) {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if token != json.Delim('[') {
+		return nil, fmt.Errorf("transactions must be an array, got %v", token)
+	}
+
+	var transactions []domain.Transaction
+	users := make(map[uint]bool)
+	for decoder.More() {
+		if limits.MaxTransactions > 0 && int64(len(transactions)) == limits.MaxTransactions {
+			return nil, limitError{Limit: "max_transactions", Max: limits.MaxTransactions}
+		}
+		var transaction domain.Transaction
+		if err := decoder.Decode(&transaction); err != nil {
+			return nil, err
+		}
+		users[transaction.UserId] = true
+		if limits.MaxUsers > 0 && int64(len(users)) > limits.MaxUsers {
+			return nil, limitError{Limit: "max_users", Max: limits.MaxUsers}
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, expectDelimiter(decoder, ']')
+}
+
+// checkBatch fails with a limitError when transactions decoded some other way, as gRPC does, are more or come from
+// more distinct users than the limits allow
+func (limits requestLimits) checkBatch(transactions []domain.Transaction) error {
+	if limits.MaxTransactions > 0 && int64(len(transactions)) > limits.MaxTransactions {
+		return limitError{Limit: "max_transactions", Max: limits.MaxTransactions}
+	}
+	if limits.MaxUsers <= 0 {
+		return nil
+	}
+	users := make(map[uint]bool)
+	for _, transaction := range transactions {
+		users[transaction.UserId] = true
+		if int64(len(users)) > limits.MaxUsers {
+			return limitError{Limit: "max_users", Max: limits.MaxUsers}
+		}
+	}
+	return nil
+}
+
+// reads the next token, failing unless it is the delimiter
+func expectDelimiter(decoder *json.Decoder, delimiter json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delimiter {
+		return fmt.Errorf("expected %v, got %v", delimiter, token)
+	}
+	return nil
+}
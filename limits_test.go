@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// replaces the request limits for the test
+func setTestLimits(t *testing.T, limits requestLimits) {
+	previous := batchLimits
+	batchLimits = limits
+	t.Cleanup(func() { batchLimits = previous })
+}
+
+func TestDecodeTransactionsInput(t *testing.T) {
+	limits := requestLimits{MaxTransactions: 3, MaxUsers: 2}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantIds   []uint
+		wantLimit string
+		wantErr   bool
+	}{
+		{
+			name:    "transactions should be decoded in order",
+			body:    `{"transactions": [{"id": 1, "user_id": 1}, {"id": 2, "user_id": 2}]}`,
+			wantIds: []uint{1, 2},
+		},
+		{
+			name:    "unknown fields should be ignored and the field name matched ignoring case",
+			body:    `{"source": {"name": "gateway"}, "Transactions": [{"id": 1, "user_id": 1}]}`,
+			wantIds: []uint{1},
+		},
+		{
+			name: "null transactions should be none",
+			body: `{"transactions": null}`,
+		},
+		{
+			name:    "the same users should count once",
+			body:    `{"transactions": [{"id": 1, "user_id": 1}, {"id": 2, "user_id": 2}, {"id": 3, "user_id": 1}]}`,
+			wantIds: []uint{1, 2, 3},
+		},
+		{
+			name:      "more transactions than the limit should fail",
+			body:      `{"transactions": [{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}]}`,
+			wantLimit: "max_transactions",
+		},
+		{
+			name:      "more users than the limit should fail",
+			body:      `{"transactions": [{"id": 1, "user_id": 1}, {"id": 2, "user_id": 2}, {"id": 3, "user_id": 3}]}`,
+			wantLimit: "max_users",
+		},
+		{
+			name: "the limit should be reported before the rest of the body is read",
+			// the invalid end is never reached
+			body:      `{"transactions": [{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}, !`,
+			wantLimit: "max_transactions",
+		},
+		{
+			name:    "a body other than an object should fail",
+			body:    `[{"id": 1}]`,
+			wantErr: true,
+		},
+		{
+			name:    "transactions other than an array should fail",
+			body:    `{"transactions": {"id": 1}}`,
+			wantErr: true,
+		},
+		{
+			name:    "a truncated body should fail",
+			body:    `{"transactions": [`,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := decodeTransactionsInput(strings.NewReader(test.body), limits)
+			if test.wantLimit != "" {
+				assert.ErrorAs(t, err, &limitError{})
+				assert.Equal(t, test.wantLimit, err.(limitError).Limit)
+				return
+			}
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			var ids []uint
+			for _, transaction := range got.InputTransactions {
+				ids = append(ids, transaction.TransactionId)
+			}
+			assert.Equal(t, test.wantIds, ids)
+		})
+	}
+}
+
+func TestDecodeTransactionsInput_NoLimits(t *testing.T) {
+	body := `{"transactions": [{"id": 1, "user_id": 1}, {"id": 2, "user_id": 2}, {"id": 3, "user_id": 3}]}`
+	got, err := decodeTransactionsInput(strings.NewReader(body), requestLimits{})
+	assert.NoError(t, err)
+	assert.Len(t, got.InputTransactions, 3)
+}
+
+func TestRequestLimits_CheckBatch(t *testing.T) {
+	limits := requestLimits{MaxTransactions: 3, MaxUsers: 2}
+
+	tests := []struct {
+		name         string
+		transactions []domain.Transaction
+		want         error
+	}{
+		{name: "within the limits", transactions: []domain.Transaction{{UserId: 1}, {UserId: 2}, {UserId: 1}}},
+		{name: "too many transactions", transactions: []domain.Transaction{{UserId: 1}, {UserId: 1}, {UserId: 1}, {UserId: 1}}, want: limitError{Limit: "max_transactions", Max: 3}},
+		{name: "too many users", transactions: []domain.Transaction{{UserId: 1}, {UserId: 2}, {UserId: 3}}, want: limitError{Limit: "max_users", Max: 2}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, limits.checkBatch(test.transactions))
+		})
+	}
+	assert.NoError(t, requestLimits{}.checkBatch([]domain.Transaction{{UserId: 1}, {UserId: 2}, {UserId: 3}}))
+}
+
+func TestAssessTransactions_Limits(t *testing.T) {
+	document := loadOpenAPIDocument(t)
+	setTestLimits(t, requestLimits{MaxBodyBytes: 200, MaxTransactions: 2, MaxUsers: 1})
+
+	tests := []struct {
+		name      string
+		payload   string
+		status    int
+		wantLimit string
+		wantMax   float64
+	}{
+		{
+			name:    "within the limits",
+			payload: `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 100, "card_id": 1}]}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:      "too many bytes",
+			payload:   `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 100, "card_id": 1, "user_agent": "` + strings.Repeat("a", 200) + `"}]}`,
+			status:    http.StatusRequestEntityTooLarge,
+			wantLimit: "max_body_bytes",
+			wantMax:   200,
+		},
+		{
+			name:      "too many transactions",
+			payload:   `{"transactions": [{"id": 1, "user_id": 1}, {"id": 2, "user_id": 1}, {"id": 3, "user_id": 1}]}`,
+			status:    http.StatusRequestEntityTooLarge,
+			wantLimit: "max_transactions",
+			wantMax:   2,
+		},
+		{
+			name:      "too many users",
+			payload:   `{"transactions": [{"id": 1, "user_id": 1}, {"id": 2, "user_id": 2}]}`,
+			status:    http.StatusRequestEntityTooLarge,
+			wantLimit: "max_users",
+			wantMax:   1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveTestRequest("POST", "/check_transactions", test.payload, nil)
+			assert.Equal(t, test.status, recorder.Code)
+
+			var body map[string]any
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			assert.Empty(t, validateSchema(document, responseSchema(t, document, "POST", "/check_transactions", recorder.Code), body, "response"))
+			if test.wantLimit != "" {
+				assert.Equal(t, test.wantLimit, body["limit"])
+				assert.Equal(t, test.wantMax, body["max"])
+			}
+		})
+	}
+}
+
+func TestAssessTransactions_LimitsWithIdempotencyKey(t *testing.T) {
+	enableTestIdempotency(t)
+	setTestLimits(t, requestLimits{MaxBodyBytes: 10})
+
+	// the body is not read whole before its limit is checked
+	recorder := serveTestRequest("POST", "/check_transactions", `{"transactions": []}`, map[string]string{idempotencyKeyHeader: "large"})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "max_body_bytes")
+}
+
+func TestLoadRequestLimits(t *testing.T) {
+	setTestLimits(t, requestLimits{MaxBodyBytes: 100, MaxTransactions: 10, MaxUsers: 5})
+
+	t.Setenv("MAX_TRANSACTIONS", "20")
+	t.Setenv("MAX_USERS", "0")
+	got, err := loadRequestLimits()
+	assert.NoError(t, err)
+	// unset variables keep the current limit
+	assert.Equal(t, requestLimits{MaxBodyBytes: 100, MaxTransactions: 20, MaxUsers: 0}, got)
+
+	t.Setenv("MAX_BODY_BYTES", "-1")
+	_, err = loadRequestLimits()
+	assert.ErrorContains(t, err, "MAX_BODY_BYTES")
+}
@@ -1,37 +1,202 @@
 package main
 
 import (
+	gocontext "context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"time"
+	"transactionriskassessment/audit"
+	"transactionriskassessment/client"
 	"transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+	"transactionriskassessment/feedback"
+	"transactionriskassessment/idempotency"
+	"transactionriskassessment/review"
+	"transactionriskassessment/ruleset"
 
 	"github.com/gin-gonic/gin"
 )
 
+// default addresses, overridden by HTTP_ADDRESS and GRPC_ADDRESS environment variables
+const (
+	defaultHTTPAddress = "localhost:9090"
+	defaultGRPCAddress = "localhost:9091"
+)
+
+// risk engine shared by the HTTP and gRPC servers
+var riskEngine = engine.New(engine.WithFraudLabels(feedbackStore))
+
 /**
-* assessTransactions receives a JSON from request body, calls RelateUserToTransactions to map
-* user ids to its respective transactions and finally calls CheckTransactions to assess the risk
-* for each transaction according to requirement's rules, returning the JSON with their risks
+* assessTransactions receives a JSON from request body and calls the risk engine of the tenant, which maps
+* user ids to its respective transactions and assesses the risk for each transaction according
+* to requirement's rules, returning the JSON with their risks
  */
 func AssessTransactions(context *gin.Context) {
-	var newTransactionsList domain.TransactionsInput
-
-	// if API input is not what's expected, end the function
-	if err := context.BindJSON(&newTransactionsList); err != nil {
+	apiKey := context.GetHeader(client.APIKeyHeader)
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), apiKey)
+	if err != nil {
 		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// call API logic
-	mappedUserTransac := RelateUserToTransactions(newTransactionsList.InputTransactions)
-	resultantRatings := CheckTransactions(mappedUserTransac)
+	// if API input is not what's expected, or more than the limits allow, end the function
+	newTransactionsList, err := decodeTransactionsInput(context.Request.Body, batchLimits)
+	if err != nil {
+		respondBodyError(context, err)
+		return
+	}
+
+	requestId := context.GetHeader(requestIdHeader)
+	if requestId == "" {
+		requestId = newRequestId()
+	}
+	context.Header(requestIdHeader, requestId)
 
 	// call function that process and returns transaction risks
+	clientId := clientIdentity(apiKey, context.ClientIP())
+	resultantRatings, err := assessAndRecord(context.Request.Context(), tenant, requestId, clientId, newTransactionsList.InputTransactions)
+	if err != nil {
+		context.JSON(assessmentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 	context.IndentedJSON(http.StatusOK, resultantRatings)
 }
 
+// GetVersion reports the rule set version of the tenant, as recorded in the audit trail, and the limits of
+// POST /check_transactions, so clients can split their batches before being refused
+func GetVersion(context *gin.Context) {
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	context.JSON(http.StatusOK, gin.H{"rule_set_version": tenant.engine.Version(), "limits": batchLimits})
+}
+
+// HTTP status of an assessment error
+func assessmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, audit.ErrDuplicateRequest):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrMoneyOverflow), errors.Is(err, domain.ErrCurrencyMismatch), errors.Is(err, domain.ErrInvalidTransaction):
+		// a transaction cannot be assessed, or a user's amounts cannot be summed
+		return http.StatusBadRequest
+	case errors.Is(err, gocontext.Canceled), errors.Is(err, gocontext.DeadlineExceeded):
+		// only happens when the client gives up on the request
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// returns the value of the environment variable or the fallback when it is unset
+func envOrDefault(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// loadRiskEngine builds the engine from the JSON file at RULES_CONFIG, or from the default configuration when unset.
+// When SHADOW_RULES_CONFIG is set, the rules of that file are also assessed, see loadShadow
+func loadRiskEngine() (*engine.Engine, error) {
+	// the server remembers previous requests, so rules can look beyond a single batch
+	options := []engine.Option{engine.WithHistory(engine.NewHistory()), engine.WithFraudLabels(feedbackStore)}
+
+	if path := envOrDefault("RULES_CONFIG", ""); path != "" {
+		config, err := engine.LoadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, engine.WithConfig(config))
+	}
+	// challenger rules, assessed on every request but only logged
+	if path := envOrDefault("SHADOW_RULES_CONFIG", ""); path != "" {
+		shadow, err := loadShadow(path, envOrDefault("SHADOW_LOG", "shadow_divergences.log"))
+		if err != nil {
+			return nil, fmt.Errorf("shadow: %w", err)
+		}
+		options = append(options, engine.WithShadow(shadow))
+	}
+	return engine.New(options...), nil
+}
+
+// setupRouter creates the HTTP server with the API routes registered
+func setupRouter() *gin.Engine {
+	router := gin.Default()
+	router.POST("/check_transactions", limitRequestBody, idempotent, AssessTransactions)
+	router.GET("/version", GetVersion)
+	router.GET("/rule_versions", ListRuleVersions)
+	router.POST("/rule_versions", requireRulesAdmin, PostRuleVersion)
+	router.POST("/rule_versions/:number/activate", requireRulesAdmin, ActivateRuleVersion)
+	router.GET("/assessments/:request_id", GetAssessment)
+	router.POST("/feedback", PostFeedback)
+	router.GET("/reviews", ListReviews)
+	router.POST("/reviews/:id/claim", ClaimReview)
+	router.POST("/reviews/:id/approve", ApproveReview)
+	router.POST("/reviews/:id/reject", RejectReview)
+	router.GET("/openapi.json", GetOpenAPI)
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+	return router
+}
+
 func main() {
+	var err error
+	// feedback is loaded first, the engine reads it
+	if feedbackStore, err = feedback.Open(envOrDefault("FEEDBACK_LOG", "")); err != nil {
+		log.Fatalf("opening feedback: %v", err)
+	}
+	if riskEngine, err = loadRiskEngine(); err != nil {
+		log.Fatalf("loading rules configuration: %v", err)
+	}
+	rulesConfigPath, rulesAdminKey = envOrDefault("RULES_CONFIG", ""), envOrDefault("RULES_ADMIN_KEY", "")
+	if path := envOrDefault("TENANTS_CONFIG", ""); path != "" {
+		if tenants, tenantsByAPIKey, err = loadTenants(path); err != nil {
+			log.Fatalf("loading tenants: %v", err)
+		}
+	}
+	// versions are restored once every tenant has its rules
+	if ruleVersions, err = ruleset.Open(envOrDefault("RULE_VERSIONS_LOG", "")); err != nil {
+		log.Fatalf("opening rule versions: %v", err)
+	}
+	if err = restoreRuleVersions(); err != nil {
+		log.Fatalf("restoring rule versions: %v", err)
+	}
+	if path := envOrDefault("AUDIT_LOG", ""); path != "" {
+		if auditStore, err = audit.Open(path); err != nil {
+			log.Fatalf("opening audit trail: %v", err)
+		}
+	}
+	if batchLimits, err = loadRequestLimits(); err != nil {
+		log.Fatalf("loading request limits: %v", err)
+	}
+	idempotencyTTL, err := time.ParseDuration(envOrDefault("IDEMPOTENCY_TTL", defaultIdempotencyTTL.String()))
+	if err != nil {
+		log.Fatalf("IDEMPOTENCY_TTL: %v", err)
+	}
+	if idempotencyTTL > 0 {
+		idempotencyStore = idempotency.New(idempotencyTTL)
+	}
+	if path := envOrDefault("REVIEW_LOG", ""); path != "" {
+		if reviewMinRisk, err = domain.ParseRiskLevel(envOrDefault("REVIEW_MIN_RISK", reviewMinRisk.String())); err != nil {
+			log.Fatalf("REVIEW_MIN_RISK: %v", err)
+		}
+		if reviewQueue, err = review.Open(path); err != nil {
+			log.Fatalf("opening review queue: %v", err)
+		}
+	}
+
+	// gRPC server runs alongside the HTTP one, on its own port
+	go func() {
+		if err := runGRPCServer(envOrDefault("GRPC_ADDRESS", defaultGRPCAddress)); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+
 	// server to run the API
-	router := gin.Default()
-	router.POST("/check_transactions", AssessTransactions)
-	router.Run("localhost:9090")
+	router := setupRouter()
+	router.Run(envOrDefault("HTTP_ADDRESS", defaultHTTPAddress))
 }
@@ -2,10 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
+	"transactionriskassessment/client"
+	"transactionriskassessment/domain"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -115,6 +118,22 @@ func TestAssessTransactions_InvalidInput(t *testing.T) {
 }
 
 func TestMain(t *testing.T) {
+	// main replaces the shared globals by its own, the other tests expect the defaults
+	previousEngine, previousFeedback, previousAudit := riskEngine, feedbackStore, auditStore
+	previousReviews, previousMinRisk := reviewQueue, reviewMinRisk
+	previousTenants, previousTenantsByAPIKey := tenants, tenantsByAPIKey
+	previousIdempotency := idempotencyStore
+	previousLimits := batchLimits
+	previousVersions, previousRulesConfig, previousAdminKey := ruleVersions, rulesConfigPath, rulesAdminKey
+	t.Cleanup(func() {
+		riskEngine, feedbackStore, auditStore = previousEngine, previousFeedback, previousAudit
+		reviewQueue, reviewMinRisk = previousReviews, previousMinRisk
+		tenants, tenantsByAPIKey = previousTenants, previousTenantsByAPIKey
+		idempotencyStore = previousIdempotency
+		batchLimits = previousLimits
+		ruleVersions, rulesConfigPath, rulesAdminKey = previousVersions, previousRulesConfig, previousAdminKey
+	})
+
 	// Run the main function in a separate goroutine
 	go main()
 
@@ -135,3 +154,38 @@ func TestMain(t *testing.T) {
 		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.StatusCode)
 	}
 }
+
+// Tests the client package against the real router, served by an httptest server
+func TestClientAgainstRouter(t *testing.T) {
+	server := httptest.NewServer(setupRouter())
+	defer server.Close()
+
+	transactions := []domain.Transaction{
+		{TransactionId: 1, UserId: 1, Amount: domain.USD(200000), IdCardUsed: 1},
+		{TransactionId: 2, UserId: 1, Amount: domain.USD(600000), IdCardUsed: 1},
+		{TransactionId: 3, UserId: 1, Amount: domain.USD(1100000), IdCardUsed: 1},
+		{TransactionId: 4, UserId: 2, Amount: domain.USD(100000), IdCardUsed: 2},
+		{TransactionId: 5, UserId: 2, Amount: domain.USD(100000), IdCardUsed: 3},
+		{TransactionId: 6, UserId: 2, Amount: domain.USD(100000), IdCardUsed: 4},
+	}
+	wantRisks := []domain.RiskLevel{domain.LOW, domain.MEDIUM, domain.HIGH, domain.LOW, domain.MEDIUM, domain.HIGH}
+
+	results, err := client.New(server.URL, client.WithAPIKey("key")).AssessTransactions(context.Background(), transactions)
+	assert.NoError(t, err)
+	assert.Len(t, results, len(transactions))
+	for index, result := range results {
+		assert.Equal(t, transactions[index], result.Transaction)
+		assert.Equal(t, wantRisks[index], result.Risk)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	setTestLimits(t, requestLimits{MaxBodyBytes: 100, MaxTransactions: 10, MaxUsers: 5})
+
+	status, body := serveDocumentedRequest(t, "GET", "/version", "/version", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"rule_set_version": "`+riskEngine.Config().Version()+`", "limits": {"max_body_bytes": 100, "max_transactions": 10, "max_users": 5}}`, string(body))
+
+	recorder := serveTestRequest("GET", "/version", "", map[string]string{tenantHeader: "unknown"})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
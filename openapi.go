@@ -0,0 +1,18 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPI 3 contract of the HTTP API, kept next to the handlers so both change together
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// GetOpenAPI serves the OpenAPI document describing the API
+func GetOpenAPI(context *gin.Context) {
+	context.Data(http.StatusOK, "application/json", openAPIDocument)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodes the embedded document, failing the test if it is not valid JSON
+func loadOpenAPIDocument(t *testing.T) map[string]any {
+	var document map[string]any
+	if err := json.Unmarshal(openAPIDocument, &document); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return document
+}
+
+// follows a "#/components/schemas/Name" reference
+func resolveSchema(document map[string]any, schema map[string]any) map[string]any {
+	reference, ok := schema["$ref"].(string)
+	if !ok {
+		return schema
+	}
+	node := any(document)
+	for _, key := range strings.Split(strings.TrimPrefix(reference, "#/"), "/") {
+		node = node.(map[string]any)[key]
+	}
+	return resolveSchema(document, node.(map[string]any))
+}
+
+// validateSchema checks value against the subset of JSON schema used by openapi.json,
+// returning one message per mismatch
+func validateSchema(document map[string]any, schema map[string]any, value any, path string) []string {
+	schema = resolveSchema(document, schema)
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		return []string{path + ": null is not allowed"}
+	}
+
+	if alternatives, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, alternative := range alternatives {
+			if len(validateSchema(document, alternative.(map[string]any), value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s: %v matches %d of the oneOf schemas", path, value, matches)}
+		}
+		return nil
+	}
+
+	var problems []string
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %T", path, value)}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		// sorted so messages come out in a stable order
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := properties[name].(map[string]any)
+			if !ok {
+				if additional, _ := schema["additionalProperties"].(bool); !additional && schema["additionalProperties"] != nil {
+					problems = append(problems, fmt.Sprintf("%s: property %q is not in the spec", path, name))
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(document, property, object[name], path+"."+name)...)
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %T", path, value)}
+		}
+		items, _ := schema["items"].(map[string]any)
+		for index, item := range array {
+			problems = append(problems, validateSchema(document, items, item, path+"["+strconv.Itoa(index)+"]")...)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected string, got %T", path, value)}
+		}
+		if enum, ok := schema["enum"].([]any); ok {
+			found := false
+			for _, allowed := range enum {
+				found = found || allowed == text
+			}
+			if !found {
+				problems = append(problems, fmt.Sprintf("%s: %q is not one of %v", path, text, enum))
+			}
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(text) {
+			problems = append(problems, fmt.Sprintf("%s: %q does not match %s", path, text, pattern))
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return []string{fmt.Sprintf("%s: expected integer, got %v", path, value)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: expected number, got %T", path, value)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected boolean, got %T", path, value)}
+		}
+	}
+	return problems
+}
+
+// returns the schema documented for a response, failing if the status code is not documented
+func responseSchema(t *testing.T, document map[string]any, method, path string, status int) map[string]any {
+	operation, ok := document["paths"].(map[string]any)[path].(map[string]any)[strings.ToLower(method)].(map[string]any)
+	if !ok {
+		t.Fatalf("%s %s is not in the spec", method, path)
+	}
+	response, ok := operation["responses"].(map[string]any)[strconv.Itoa(status)].(map[string]any)
+	if !ok {
+		t.Fatalf("%s %s response %d is not in the spec", method, path, status)
+	}
+	return response["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+}
+
+func TestGetOpenAPI(t *testing.T) {
+	router := setupRouter()
+	recorder := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/openapi.json", nil)
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var document map[string]any
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &document))
+	assert.True(t, strings.HasPrefix(document["openapi"].(string), "3."))
+}
+
+// Fails if the JSON actually produced by the handler drifts from what openapi.json describes
+func TestOpenAPIMatchesHandlerResponses(t *testing.T) {
+	document := loadOpenAPIDocument(t)
+	router := setupRouter()
+
+	tests := []struct {
+		name    string
+		payload string
+		status  int
+	}{
+		{
+			name:    "ratings",
+			payload: `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 600000, "card_id": 1}, {"id": 2, "user_id": 1, "amount_us_cents": 1, "card_id": 2}]}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "decimal amounts",
+			payload: `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": "6000.00", "card_id": 1}, {"id": 2, "user_id": 1, "amount_us_cents": "0.01", "card_id": 2}]}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "refund and reversal",
+			payload: `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 1500000, "card_id": 1}, {"id": 2, "user_id": 1, "amount_us_cents": 1500000, "card_id": 1, "type": "reversal", "reference_id": 1}]}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "no transactions",
+			payload: `{"transactions": []}`,
+			status:  http.StatusOK,
+		},
+		{
+			name:    "invalid body",
+			payload: `{"transactions": [`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "invalid amount",
+			payload: `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": "10.505", "card_id": 1}]}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "reversal without reference",
+			payload: `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 100, "card_id": 1, "type": "reversal"}]}`,
+			status:  http.StatusBadRequest,
+		},
+		{
+			name:    "overflowing total",
+			payload: `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 9223372036854775807, "card_id": 1}, {"id": 2, "user_id": 1, "amount_us_cents": 1, "card_id": 1}]}`,
+			status:  http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// every payload sent must itself follow the request schema, unless it is meant to be invalid
+			if test.status == http.StatusOK {
+				var payload any
+				assert.NoError(t, json.Unmarshal([]byte(test.payload), &payload))
+				assert.Empty(t, validateSchema(document, map[string]any{"$ref": "#/components/schemas/TransactionsInput"}, payload, "request"))
+			}
+
+			request, _ := http.NewRequest("POST", "/check_transactions", bytes.NewBufferString(test.payload))
+			request.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+
+			assert.Equal(t, test.status, recorder.Code)
+			var body any
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &body))
+			schema := responseSchema(t, document, "POST", "/check_transactions", recorder.Code)
+			assert.Empty(t, validateSchema(document, schema, body, "response"))
+		})
+	}
+}
+
+// Fails if a field is added to the domain input types without being documented
+func TestOpenAPIMatchesDomainInput(t *testing.T) {
+	document := loadOpenAPIDocument(t)
+
+	latitude, longitude, timestamp := -23.55, -46.63, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	content, err := json.Marshal(domain.TransactionsInput{InputTransactions: []domain.Transaction{
+		// every field filled, so the optional ones are marshaled too
+		{
+			TransactionId: 1, UserId: 2, Amount: domain.USD(300), IdCardUsed: 4, RiskRate: domain.MEDIUM, MerchantId: 5, MerchantCategoryCode: "5411",
+			Country: "BR", CardCountry: "BR", Latitude: &latitude, Longitude: &longitude, Timestamp: &timestamp,
+			DeviceId: "device", IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0", Type: domain.Reversal, ReferenceId: 3,
+		},
+	}})
+	assert.NoError(t, err)
+	var input any
+	assert.NoError(t, json.Unmarshal(content, &input))
+
+	assert.Empty(t, validateSchema(document, map[string]any{"$ref": "#/components/schemas/TransactionsInput"}, input, "input"))
+}
@@ -0,0 +1,248 @@
+// Package review keeps the queue of risky transactions waiting for an analyst decision
+package review
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"transactionriskassessment/domain"
+)
+
+// Status is where an item is in the review
+type Status string
+
+const (
+	Pending  Status = "pending"
+	Claimed  Status = "claimed"
+	Approved Status = "approved"
+	Rejected Status = "rejected"
+)
+
+var (
+	// ErrNotFound is returned for item ids not in the queue
+	ErrNotFound = errors.New("review item not found")
+	// ErrClaimed is returned when another analyst already claimed the item
+	ErrClaimed = errors.New("review item claimed by another analyst")
+	// ErrDecided is returned when the item was already approved or rejected
+	ErrDecided = errors.New("review item already decided")
+	// ErrNoAnalyst is returned when the analyst is missing
+	ErrNoAnalyst = errors.New("analyst is required")
+)
+
+// Item is a transaction waiting for, or given, a decision
+type Item struct {
+	Id           uint64             `json:"id"`
+	RequestId    string             `json:"request_id"`
+	Tenant       string             `json:"tenant,omitempty"`
+	Transaction  domain.Transaction `json:"transaction"`
+	Risk         string             `json:"risk"`
+	MatchedRules []string           `json:"matched_rules"`
+	EnqueuedAt   time.Time          `json:"enqueued_at"`
+	Status       Status             `json:"status"`
+	// who claimed or decided the item
+	Analyst   string     `json:"analyst,omitempty"`
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+	Note      string     `json:"note,omitempty"`
+}
+
+// IsOpen tells whether the item still waits for a decision
+func (item Item) IsOpen() bool {
+	return item.Status == Pending || item.Status == Claimed
+}
+
+// Queue holds the review items in memory and, when it has a path, appends every change to a local file.
+// It is safe for concurrent use
+type Queue struct {
+	mutex sync.Mutex
+	// nil for queues kept only in memory
+	file   *os.File
+	items  map[uint64]Item
+	lastId uint64
+}
+
+// Open loads the queue file at path, creating it when missing. An empty path keeps the queue in memory only
+func Open(path string) (*Queue, error) {
+	queue := &Queue{items: make(map[uint64]Item)}
+	if path == "" {
+		return queue, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	// every line is the latest state of an item
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var item Item
+		if err := json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		queue.items[item.Id] = item
+		queue.lastId = max(queue.lastId, item.Id)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	queue.file = file
+	return queue, nil
+}
+
+// saves the items state, in the file first so memory never holds a change that was not persisted. The items are
+// written at once, so either all of them are saved or none
+func (queue *Queue) save(items ...Item) error {
+	if queue.file != nil {
+		var lines []byte
+		for _, item := range items {
+			line, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			lines = append(append(lines, line...), '\n')
+		}
+		if _, err := queue.file.Write(lines); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		queue.items[item.Id] = item
+	}
+	return nil
+}
+
+// Enqueue adds the items as pending, setting their id and enqueue time, and returns them. When it fails, none of
+// them is queued, so enqueueing them again does not duplicate any
+func (queue *Queue) Enqueue(items ...Item) ([]Item, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	now := time.Now().UTC()
+	for index := range items {
+		items[index].Id = queue.lastId + uint64(index) + 1
+		items[index].EnqueuedAt = now
+		items[index].Status = Pending
+	}
+	if err := queue.save(items...); err != nil {
+		return nil, err
+	}
+	queue.lastId += uint64(len(items))
+	return items, nil
+}
+
+// List returns the items with one of the statuses, the riskiest first and, for the same risk, the oldest first
+func (queue *Queue) List(statuses ...Status) []Item {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	wanted := make(map[Status]bool, len(statuses))
+	for _, status := range statuses {
+		wanted[status] = true
+	}
+	var items []Item
+	for _, item := range queue.items {
+		if wanted[item.Status] {
+			items = append(items, item)
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		iRisk, _ := domain.ParseRiskLevel(items[i].Risk)
+		jRisk, _ := domain.ParseRiskLevel(items[j].Risk)
+		if iRisk != jRisk {
+			return iRisk > jRisk
+		}
+		// ids grow with time, they order items enqueued at the same moment
+		return items[i].Id < items[j].Id
+	})
+	return items
+}
+
+// Get returns the item with the id
+func (queue *Queue) Get(id uint64) (Item, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	item, isQueued := queue.items[id]
+	if !isQueued {
+		return Item{}, ErrNotFound
+	}
+	return item, nil
+}
+
+// Claim assigns a pending item to the analyst, so others know it is being reviewed. Claiming it again is allowed
+func (queue *Queue) Claim(id uint64, analyst string) (Item, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	item, err := queue.openItem(id, analyst)
+	if err != nil {
+		return item, err
+	}
+	if item.Status == Claimed {
+		return item, nil
+	}
+
+	now := time.Now().UTC()
+	item.Status, item.Analyst, item.ClaimedAt = Claimed, analyst, &now
+	return item, queue.save(item)
+}
+
+// Approve decides the item is legitimate
+func (queue *Queue) Approve(id uint64, analyst, note string) (Item, error) {
+	return queue.decide(id, analyst, note, Approved)
+}
+
+// Reject decides the item is fraudulent
+func (queue *Queue) Reject(id uint64, analyst, note string) (Item, error) {
+	return queue.decide(id, analyst, note, Rejected)
+}
+
+// sets the decision of an open item, pending or claimed by the same analyst
+func (queue *Queue) decide(id uint64, analyst, note string, status Status) (Item, error) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	item, err := queue.openItem(id, analyst)
+	if err != nil {
+		return item, err
+	}
+
+	now := time.Now().UTC()
+	item.Status, item.Analyst, item.DecidedAt, item.Note = status, analyst, &now, note
+	return item, queue.save(item)
+}
+
+// returns the item when the analyst can still act on it
+func (queue *Queue) openItem(id uint64, analyst string) (Item, error) {
+	if analyst == "" {
+		return Item{}, ErrNoAnalyst
+	}
+	item, isQueued := queue.items[id]
+	if !isQueued {
+		return item, ErrNotFound
+	}
+	if !item.IsOpen() {
+		return item, ErrDecided
+	}
+	if item.Status == Claimed && item.Analyst != analyst {
+		return item, ErrClaimed
+	}
+	return item, nil
+}
+
+// Close closes the file, the queue cannot be used afterwards
+func (queue *Queue) Close() error {
+	if queue.file == nil {
+		return nil
+	}
+	return queue.file.Close()
+}
@@ -0,0 +1,111 @@
+package review
+
+import (
+	"path/filepath"
+	"testing"
+	"transactionriskassessment/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueList_Order(t *testing.T) {
+	queue, err := Open("")
+	assert.NoError(t, err)
+
+	_, err = queue.Enqueue(
+		Item{Transaction: domain.Transaction{TransactionId: 1}, Risk: "medium"},
+		Item{Transaction: domain.Transaction{TransactionId: 2}, Risk: "high"},
+		Item{Transaction: domain.Transaction{TransactionId: 3}, Risk: "medium"},
+	)
+	assert.NoError(t, err)
+	_, err = queue.Enqueue(Item{Transaction: domain.Transaction{TransactionId: 4}, Risk: "high"})
+	assert.NoError(t, err)
+
+	var transactionIds []uint
+	for _, item := range queue.List(Pending) {
+		transactionIds = append(transactionIds, item.Transaction.TransactionId)
+	}
+	// riskiest first, then oldest first
+	assert.Equal(t, []uint{2, 4, 1, 3}, transactionIds)
+	assert.Empty(t, queue.List(Approved))
+}
+
+func TestQueue_Workflow(t *testing.T) {
+	queue, err := Open("")
+	assert.NoError(t, err)
+	items, err := queue.Enqueue(Item{Risk: "high"}, Item{Risk: "medium"})
+	assert.NoError(t, err)
+	first, second := items[0].Id, items[1].Id
+
+	tests := []struct {
+		name       string
+		action     func() (Item, error)
+		wantErr    error
+		wantStatus Status
+	}{
+		{name: "claim", action: func() (Item, error) { return queue.Claim(first, "ana") }, wantStatus: Claimed},
+		{name: "claim again by the same analyst", action: func() (Item, error) { return queue.Claim(first, "ana") }, wantStatus: Claimed},
+		{name: "claim by another analyst", action: func() (Item, error) { return queue.Claim(first, "bruno") }, wantErr: ErrClaimed},
+		{name: "approve by another analyst", action: func() (Item, error) { return queue.Approve(first, "bruno", "") }, wantErr: ErrClaimed},
+		{name: "reject", action: func() (Item, error) { return queue.Reject(first, "ana", "stolen card") }, wantStatus: Rejected},
+		{name: "approve after the decision", action: func() (Item, error) { return queue.Approve(first, "ana", "") }, wantErr: ErrDecided},
+		{name: "approve without claiming", action: func() (Item, error) { return queue.Approve(second, "bruno", "known customer") }, wantStatus: Approved},
+		{name: "no analyst", action: func() (Item, error) { return queue.Claim(second, "") }, wantErr: ErrNoAnalyst},
+		{name: "unknown item", action: func() (Item, error) { return queue.Claim(99, "ana") }, wantErr: ErrNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item, err := test.action()
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantStatus, item.Status)
+		})
+	}
+
+	rejected, err := queue.Get(first)
+	assert.NoError(t, err)
+	assert.Equal(t, "ana", rejected.Analyst)
+	assert.Equal(t, "stolen card", rejected.Note)
+	assert.NotNil(t, rejected.ClaimedAt)
+	assert.NotNil(t, rejected.DecidedAt)
+}
+
+func TestOpen_ReloadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reviews.log")
+	queue, err := Open(path)
+	assert.NoError(t, err)
+	items, err := queue.Enqueue(Item{Risk: "high"}, Item{Risk: "medium"})
+	assert.NoError(t, err)
+	_, err = queue.Approve(items[0].Id, "ana", "ok")
+	assert.NoError(t, err)
+	assert.NoError(t, queue.Close())
+
+	reopened, err := Open(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+
+	approved, err := reopened.Get(items[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, Approved, approved.Status)
+	assert.Len(t, reopened.List(Pending), 1)
+
+	// ids keep growing after a reload
+	added, err := reopened.Enqueue(Item{Risk: "high"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), added[0].Id)
+}
+
+func TestQueueEnqueue_AllOrNothing(t *testing.T) {
+	queue, err := Open(filepath.Join(t.TempDir(), "reviews.log"))
+	assert.NoError(t, err)
+	// writing fails from now on
+	assert.NoError(t, queue.file.Close())
+
+	_, err = queue.Enqueue(Item{Risk: "high"}, Item{Risk: "medium"})
+	assert.Error(t, err)
+	assert.Empty(t, queue.List(Pending))
+	assert.Equal(t, uint64(0), queue.lastId)
+}
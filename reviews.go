@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"transactionriskassessment/client"
+	"transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+	"transactionriskassessment/review"
+
+	"github.com/gin-gonic/gin"
+)
+
+// review queue of risky transactions, nil unless REVIEW_LOG is set
+var reviewQueue *review.Queue
+
+// transactions rated at or above this risk are enqueued for review, set by REVIEW_MIN_RISK
+var reviewMinRisk = domain.MEDIUM
+
+// statuses listed when GET /reviews has no status parameter
+var openStatuses = []review.Status{review.Pending, review.Claimed}
+
+// enqueues the assessed transactions risky enough to be reviewed, when the queue is enabled
+func enqueueForReview(tenantId, requestId string, transactions []domain.Transaction, assessments []engine.Assessment) error {
+	if reviewQueue == nil {
+		return nil
+	}
+
+	var items []review.Item
+	for index, assessment := range assessments {
+		if assessment.Transaction.RiskRate < reviewMinRisk {
+			continue
+		}
+		items = append(items, review.Item{
+			RequestId:    requestId,
+			Tenant:       tenantId,
+			Transaction:  transactions[index],
+			Risk:         assessment.Transaction.RiskRate.String(),
+			MatchedRules: assessment.MatchedRules,
+		})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := reviewQueue.Enqueue(items...)
+	return err
+}
+
+// reviewDecisionInput is the body of the claim, approve and reject endpoints
+type reviewDecisionInput struct {
+	Analyst string `json:"analyst"`
+	Note    string `json:"note"`
+}
+
+// HTTP status of a review queue error
+func reviewErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, review.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, review.ErrClaimed), errors.Is(err, review.ErrDecided):
+		return http.StatusConflict
+	case errors.Is(err, review.ErrNoAnalyst):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// answers 404 and returns false when the review queue is disabled
+func reviewQueueEnabled(context *gin.Context) bool {
+	if reviewQueue == nil {
+		context.JSON(http.StatusNotFound, gin.H{"error": "review queue is disabled"})
+		return false
+	}
+	return true
+}
+
+// ListReviews returns the review items of the tenant, open ones unless the status parameter lists others (comma
+// separated)
+func ListReviews(context *gin.Context) {
+	if !reviewQueueEnabled(context) {
+		return
+	}
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statuses := openStatuses
+	if parameter := context.Query("status"); parameter != "" {
+		statuses = nil
+		for _, name := range strings.Split(parameter, ",") {
+			status := review.Status(name)
+			if status != review.Pending && status != review.Claimed && status != review.Approved && status != review.Rejected {
+				context.JSON(http.StatusBadRequest, gin.H{"error": "unknown status " + strconv.Quote(name)})
+				return
+			}
+			statuses = append(statuses, status)
+		}
+	}
+
+	items := []review.Item{}
+	for _, item := range reviewQueue.List(statuses...) {
+		if tenant.owns(item.Tenant) {
+			items = append(items, item)
+		}
+	}
+	context.IndentedJSON(http.StatusOK, gin.H{"items": items})
+}
+
+// returns a handler for the claim, approve and reject endpoints, which only differ by the queue method they call.
+// Items of other tenants are not found
+func reviewAction(action func(id uint64, input reviewDecisionInput) (review.Item, error)) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if !reviewQueueEnabled(context) {
+			return
+		}
+		tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		id, err := strconv.ParseUint(context.Param("id"), 10, 64)
+		if err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": "invalid review item id"})
+			return
+		}
+		var input reviewDecisionInput
+		if err := context.BindJSON(&input); err != nil {
+			context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// items never change tenant, checking it before acting is enough
+		if item, err := reviewQueue.Get(id); err == nil && !tenant.owns(item.Tenant) {
+			context.JSON(http.StatusNotFound, gin.H{"error": review.ErrNotFound.Error()})
+			return
+		}
+		item, err := action(id, input)
+		if err != nil {
+			context.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		context.IndentedJSON(http.StatusOK, item)
+	}
+}
+
+// ClaimReview assigns a pending item to the analyst
+var ClaimReview = reviewAction(func(id uint64, input reviewDecisionInput) (review.Item, error) {
+	return reviewQueue.Claim(id, input.Analyst)
+})
+
+// ApproveReview decides an item is legitimate
+var ApproveReview = reviewAction(func(id uint64, input reviewDecisionInput) (review.Item, error) {
+	return reviewQueue.Approve(id, input.Analyst, input.Note)
+})
+
+// RejectReview decides an item is fraudulent
+var RejectReview = reviewAction(func(id uint64, input reviewDecisionInput) (review.Item, error) {
+	return reviewQueue.Reject(id, input.Analyst, input.Note)
+})
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+	"transactionriskassessment/review"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// enables the review queue, in memory, for the duration of the test. The engine is replaced by a stateless one,
+// so cards used by other tests are not shared
+func enableTestReviews(t *testing.T, minRisk domain.RiskLevel) {
+	queue, _ := review.Open("")
+	previousMinRisk, previousEngine := reviewMinRisk, riskEngine
+	reviewQueue, reviewMinRisk, riskEngine = queue, minRisk, engine.New()
+	t.Cleanup(func() { reviewQueue, reviewMinRisk, riskEngine = nil, previousMinRisk, previousEngine })
+}
+
+// sends the request, with the headers, and checks its response against openapi.json, returning the recorded status
+// and body
+func serveDocumentedRequest(t *testing.T, method, path, documentedPath, body string, headers map[string]string) (int, []byte) {
+	document := loadOpenAPIDocument(t)
+	recorder := serveTestRequest(method, path, body, headers)
+
+	var response any
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Empty(t, validateSchema(document, responseSchema(t, document, method, documentedPath, recorder.Code), response, "response"))
+	return recorder.Code, recorder.Body.Bytes()
+}
+
+func TestReviews_Workflow(t *testing.T) {
+	enableTestReviews(t, domain.MEDIUM)
+
+	// the high risk transaction is listed before the medium one, the low one is not enqueued
+	serveTestRequest("POST", "/check_transactions", `{"transactions": [
+		{"id": 1, "user_id": 1, "amount_us_cents": 600000, "card_id": 1},
+		{"id": 2, "user_id": 2, "amount_us_cents": 1100000, "card_id": 2},
+		{"id": 3, "user_id": 3, "amount_us_cents": 100, "card_id": 3}
+	]}`, map[string]string{requestIdHeader: "review-1"})
+
+	status, body := serveDocumentedRequest(t, "GET", "/reviews", "/reviews", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	var list struct{ Items []review.Item }
+	assert.NoError(t, json.Unmarshal(body, &list))
+	assert.Len(t, list.Items, 2)
+	assert.Equal(t, uint(2), list.Items[0].Transaction.TransactionId)
+	assert.Equal(t, "high", list.Items[0].Risk)
+	assert.Equal(t, "review-1", list.Items[0].RequestId)
+	assert.Equal(t, uint(1), list.Items[1].Transaction.TransactionId)
+	highRiskId := list.Items[0].Id
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "claim", path: "claim", body: `{"analyst": "ana"}`, status: http.StatusOK},
+		{name: "reject by another analyst", path: "reject", body: `{"analyst": "bruno"}`, status: http.StatusConflict},
+		{name: "reject without analyst", path: "reject", body: `{}`, status: http.StatusBadRequest},
+		{name: "reject", path: "reject", body: `{"analyst": "ana", "note": "stolen card"}`, status: http.StatusOK},
+		{name: "approve after the decision", path: "approve", body: `{"analyst": "ana"}`, status: http.StatusConflict},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, _ := serveDocumentedRequest(t, "POST", fmt.Sprintf("/reviews/%d/%s", highRiskId, test.path), "/reviews/{id}/"+test.path, test.body, nil)
+			assert.Equal(t, test.status, status)
+		})
+	}
+
+	status, body = serveDocumentedRequest(t, "GET", "/reviews?status=rejected", "/reviews", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NoError(t, json.Unmarshal(body, &list))
+	assert.Len(t, list.Items, 1)
+	assert.Equal(t, "stolen card", list.Items[0].Note)
+
+	status, _ = serveDocumentedRequest(t, "POST", "/reviews/99/claim", "/reviews/{id}/claim", `{"analyst": "ana"}`, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = serveDocumentedRequest(t, "GET", "/reviews?status=lost", "/reviews", "", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestReviews_MinRisk(t *testing.T) {
+	enableTestReviews(t, domain.HIGH)
+
+	serveTestRequest("POST", "/check_transactions", `{"transactions": [
+		{"id": 1, "user_id": 1, "amount_us_cents": 600000, "card_id": 1},
+		{"id": 2, "user_id": 2, "amount_us_cents": 1100000, "card_id": 2}
+	]}`, nil)
+
+	items := reviewQueue.List(review.Pending)
+	assert.Len(t, items, 1)
+	assert.Equal(t, uint(2), items[0].Transaction.TransactionId)
+}
+
+func TestReviews_EnqueueFailure(t *testing.T) {
+	enableTestReviews(t, domain.MEDIUM)
+	enableTestAudit(t)
+	queue, err := review.Open(filepath.Join(t.TempDir(), "reviews.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// writing fails from now on
+	queue.Close()
+	reviewQueue = queue
+
+	body := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 1100000, "card_id": 1}]}`
+	headers := map[string]string{requestIdHeader: "review-failure"}
+	// the assessment is recorded and returned, only its review is missing
+	status, _ := serveDocumentedRequest(t, "POST", "/check_transactions", "/check_transactions", body, headers)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, reviewQueue.List(review.Pending))
+	_, err = auditStore.Get("review-failure")
+	assert.NoError(t, err)
+}
+
+func TestReviews_Disabled(t *testing.T) {
+	status, _ := serveDocumentedRequest(t, "GET", "/reviews", "/reviews", "", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = serveDocumentedRequest(t, "POST", "/reviews/1/approve", "/reviews/{id}/approve", `{"analyst": "ana"}`, nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestReviews_Tenants(t *testing.T) {
+	enableTestReviews(t, domain.HIGH)
+	enableTestTenants(t)
+	cards, loans := map[string]string{tenantHeader: "cards"}, map[string]string{tenantHeader: "loans"}
+	serveTestRequest("POST", "/check_transactions", `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 1100000, "card_id": 1}]}`, cards)
+	serveTestRequest("POST", "/check_transactions", `{"transactions": [{"id": 2, "user_id": 2, "amount_us_cents": 1100000, "card_id": 2}]}`, nil)
+
+	// each tenant only lists its own items
+	listed := func(headers map[string]string) []uint {
+		t.Helper()
+		recorder := serveTestRequest("GET", "/reviews", "", headers)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var list struct{ Items []review.Item }
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+		var ids []uint
+		for _, item := range list.Items {
+			ids = append(ids, item.Transaction.TransactionId)
+		}
+		return ids
+	}
+	assert.Equal(t, []uint{1}, listed(cards))
+	assert.Equal(t, []uint{1}, listed(map[string]string{"X-API-Key": "cards-key"}))
+	assert.Equal(t, []uint{2}, listed(nil))
+	assert.Empty(t, listed(loans))
+
+	// nor acts on the items of others
+	cardsItemId := reviewQueue.List(review.Pending)[0].Id
+	for _, action := range []string{"claim", "approve", "reject"} {
+		recorder := serveTestRequest("POST", fmt.Sprintf("/reviews/%d/%s", cardsItemId, action), `{"analyst": "ana"}`, loans)
+		assert.Equal(t, http.StatusNotFound, recorder.Code, action)
+	}
+	recorder := serveTestRequest("POST", fmt.Sprintf("/reviews/%d/approve", cardsItemId), `{"analyst": "ana"}`, map[string]string{tenantHeader: "unknown"})
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	recorder = serveTestRequest("POST", fmt.Sprintf("/reviews/%d/approve", cardsItemId), `{"analyst": "ana"}`, cards)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
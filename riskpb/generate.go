@@ -0,0 +1,4 @@
+// Package riskpb holds the protobuf messages and gRPC service generated from risk.proto
+package riskpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative risk.proto
@@ -0,0 +1,183 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: risk.proto
+
+package riskpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RiskAssessment_CheckTransactions_FullMethodName  = "/riskassessment.RiskAssessment/CheckTransactions"
+	RiskAssessment_StreamTransactions_FullMethodName = "/riskassessment.RiskAssessment/StreamTransactions"
+)
+
+// RiskAssessmentClient is the client API for RiskAssessment service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RiskAssessmentClient interface {
+	// CheckTransactions assesses a single batch of transactions
+	CheckTransactions(ctx context.Context, in *TransactionsInput, opts ...grpc.CallOption) (*RiskRateResults, error)
+	// StreamTransactions assesses every batch received on the stream, answering each one in order
+	StreamTransactions(ctx context.Context, opts ...grpc.CallOption) (RiskAssessment_StreamTransactionsClient, error)
+}
+
+type riskAssessmentClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRiskAssessmentClient(cc grpc.ClientConnInterface) RiskAssessmentClient {
+	return &riskAssessmentClient{cc}
+}
+
+func (c *riskAssessmentClient) CheckTransactions(ctx context.Context, in *TransactionsInput, opts ...grpc.CallOption) (*RiskRateResults, error) {
+	out := new(RiskRateResults)
+	err := c.cc.Invoke(ctx, RiskAssessment_CheckTransactions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *riskAssessmentClient) StreamTransactions(ctx context.Context, opts ...grpc.CallOption) (RiskAssessment_StreamTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RiskAssessment_ServiceDesc.Streams[0], RiskAssessment_StreamTransactions_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &riskAssessmentStreamTransactionsClient{stream}
+	return x, nil
+}
+
+type RiskAssessment_StreamTransactionsClient interface {
+	Send(*TransactionsInput) error
+	Recv() (*RiskRateResults, error)
+	grpc.ClientStream
+}
+
+type riskAssessmentStreamTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *riskAssessmentStreamTransactionsClient) Send(m *TransactionsInput) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *riskAssessmentStreamTransactionsClient) Recv() (*RiskRateResults, error) {
+	m := new(RiskRateResults)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RiskAssessmentServer is the server API for RiskAssessment service.
+// All implementations must embed UnimplementedRiskAssessmentServer
+// for forward compatibility
+type RiskAssessmentServer interface {
+	// CheckTransactions assesses a single batch of transactions
+	CheckTransactions(context.Context, *TransactionsInput) (*RiskRateResults, error)
+	// StreamTransactions assesses every batch received on the stream, answering each one in order
+	StreamTransactions(RiskAssessment_StreamTransactionsServer) error
+	mustEmbedUnimplementedRiskAssessmentServer()
+}
+
+// UnimplementedRiskAssessmentServer must be embedded to have forward compatible implementations.
+type UnimplementedRiskAssessmentServer struct {
+}
+
+func (UnimplementedRiskAssessmentServer) CheckTransactions(context.Context, *TransactionsInput) (*RiskRateResults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckTransactions not implemented")
+}
+func (UnimplementedRiskAssessmentServer) StreamTransactions(RiskAssessment_StreamTransactionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTransactions not implemented")
+}
+func (UnimplementedRiskAssessmentServer) mustEmbedUnimplementedRiskAssessmentServer() {}
+
+// UnsafeRiskAssessmentServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RiskAssessmentServer will
+// result in compilation errors.
+type UnsafeRiskAssessmentServer interface {
+	mustEmbedUnimplementedRiskAssessmentServer()
+}
+
+func RegisterRiskAssessmentServer(s grpc.ServiceRegistrar, srv RiskAssessmentServer) {
+	s.RegisterService(&RiskAssessment_ServiceDesc, srv)
+}
+
+func _RiskAssessment_CheckTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionsInput)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RiskAssessmentServer).CheckTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RiskAssessment_CheckTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RiskAssessmentServer).CheckTransactions(ctx, req.(*TransactionsInput))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RiskAssessment_StreamTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RiskAssessmentServer).StreamTransactions(&riskAssessmentStreamTransactionsServer{stream})
+}
+
+type RiskAssessment_StreamTransactionsServer interface {
+	Send(*RiskRateResults) error
+	Recv() (*TransactionsInput, error)
+	grpc.ServerStream
+}
+
+type riskAssessmentStreamTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *riskAssessmentStreamTransactionsServer) Send(m *RiskRateResults) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *riskAssessmentStreamTransactionsServer) Recv() (*TransactionsInput, error) {
+	m := new(TransactionsInput)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RiskAssessment_ServiceDesc is the grpc.ServiceDesc for RiskAssessment service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RiskAssessment_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "riskassessment.RiskAssessment",
+	HandlerType: (*RiskAssessmentServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CheckTransactions",
+			Handler:    _RiskAssessment_CheckTransactions_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTransactions",
+			Handler:       _RiskAssessment_StreamTransactions_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "risk.proto",
+}
@@ -0,0 +1,228 @@
+// Package ruleset keeps the history of the rule configurations applied to each tenant, so earlier ones can be
+// inspected and activated again
+package ruleset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for version numbers the tenant never had
+	ErrNotFound = errors.New("rule set version not found")
+	// ErrNoAuthor is returned when the author of a version is missing
+	ErrNoAuthor = errors.New("author is required")
+	// ErrInvalidConfig is returned when the configuration is not a JSON object
+	ErrInvalidConfig = errors.New("configuration must be a JSON object")
+)
+
+// Version is a configuration applied to a tenant. Versions are never changed once appended
+type Version struct {
+	// counts the versions of the tenant, from 1
+	Number uint64 `json:"number"`
+	Tenant string `json:"tenant,omitempty"`
+	// hash of the rules, the rule_set_version stamped on the assessments they made
+	RuleSetVersion string    `json:"rule_set_version"`
+	Author         string    `json:"author"`
+	CreatedAt      time.Time `json:"created_at"`
+	// number of the version activated again, 0 for new configurations
+	RestoredFrom uint64 `json:"restored_from,omitempty"`
+	// the configuration as given, settings missing from it keep their default value
+	Config json.RawMessage `json:"config"`
+	// settings changed since the previous version of the tenant
+	Diff []Change `json:"diff"`
+}
+
+// Change is a setting that differs between two configurations, Before or After is missing when the setting is
+// only in one of them
+type Change struct {
+	// dot separated keys of the setting, e.g. "merchant.new_merchant_amount_us_cents"
+	Path   string          `json:"path"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// Store holds the versions in memory and, when it has a path, appends each one to a local file.
+// It is safe for concurrent use
+type Store struct {
+	mutex sync.Mutex
+	// nil for stores kept only in memory
+	file *os.File
+	// versions of each tenant, in number order
+	versions map[string][]Version
+}
+
+// Open loads the versions file at path, creating it when missing. An empty path keeps the versions in memory only
+func Open(path string) (*Store, error) {
+	store := &Store{versions: make(map[string][]Version)}
+	if path == "" {
+		return store, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	// configurations can be longer than the default line limit
+	scanner.Buffer(nil, 16<<20)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		var version Version
+		if err := json.Unmarshal(scanner.Bytes(), &version); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		store.versions[version.Tenant] = append(store.versions[version.Tenant], version)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	store.file = file
+	return store, nil
+}
+
+// Append adds the configuration as the newest version of its tenant, setting its number, creation time and
+// diff against the previous version, and returns it
+func (store *Store) Append(version Version) (Version, error) {
+	if version.Author == "" {
+		return version, ErrNoAuthor
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, version.Config); err != nil || !bytes.HasPrefix(compacted.Bytes(), []byte("{")) {
+		return version, ErrInvalidConfig
+	}
+	version.Config = compacted.Bytes()
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	previous := json.RawMessage(`{}`)
+	versions := store.versions[version.Tenant]
+	if len(versions) > 0 {
+		previous = versions[len(versions)-1].Config
+	}
+	diff, err := Diff(previous, version.Config)
+	if err != nil {
+		return version, err
+	}
+	version.Number = uint64(len(versions)) + 1
+	version.CreatedAt = time.Now().UTC()
+	version.Diff = diff
+
+	// in the file first, so memory never holds a version that was not persisted
+	if store.file != nil {
+		line, err := json.Marshal(version)
+		if err != nil {
+			return version, err
+		}
+		if _, err := store.file.Write(append(line, '\n')); err != nil {
+			return version, err
+		}
+	}
+	store.versions[version.Tenant] = append(versions, version)
+	return version, nil
+}
+
+// List returns the versions of the tenant, the oldest first. The last one is the active one
+func (store *Store) List(tenant string) []Version {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return append([]Version(nil), store.versions[tenant]...)
+}
+
+// Get returns the version of the tenant with the number
+func (store *Store) Get(tenant string, number uint64) (Version, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	versions := store.versions[tenant]
+	if number == 0 || number > uint64(len(versions)) {
+		return Version{}, ErrNotFound
+	}
+	return versions[number-1], nil
+}
+
+// Latest returns the newest version of the tenant, false when it has none
+func (store *Store) Latest(tenant string) (Version, bool) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	versions := store.versions[tenant]
+	if len(versions) == 0 {
+		return Version{}, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// Close closes the file, the store cannot be used afterwards
+func (store *Store) Close() error {
+	if store.file == nil {
+		return nil
+	}
+	return store.file.Close()
+}
+
+// Diff returns the settings that differ between two JSON objects, sorted by path. Nested objects are compared
+// key by key, any other value, arrays included, as a whole
+func Diff(before, after json.RawMessage) ([]Change, error) {
+	beforeSettings, afterSettings := make(map[string]json.RawMessage), make(map[string]json.RawMessage)
+	if err := flatten("", before, beforeSettings); err != nil {
+		return nil, err
+	}
+	if err := flatten("", after, afterSettings); err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	for path, beforeValue := range beforeSettings {
+		if afterValue, isSet := afterSettings[path]; !isSet || !bytes.Equal(beforeValue, afterValue) {
+			changes = append(changes, Change{Path: path, Before: beforeValue, After: afterValue})
+		}
+	}
+	for path, afterValue := range afterSettings {
+		if _, isSet := beforeSettings[path]; !isSet {
+			changes = append(changes, Change{Path: path, After: afterValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// adds the leaves of the value to settings, keyed by their path under prefix
+func flatten(prefix string, value json.RawMessage, settings map[string]json.RawMessage) error {
+	var object map[string]json.RawMessage
+	if bytes.HasPrefix(bytes.TrimSpace(value), []byte("{")) {
+		if err := json.Unmarshal(value, &object); err != nil {
+			return err
+		}
+	}
+	// empty objects are leaves, otherwise adding one would not be a change
+	if len(object) == 0 {
+		if prefix == "" {
+			return nil
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, value); err != nil {
+			return err
+		}
+		settings[prefix] = compacted.Bytes()
+		return nil
+	}
+	for key, nested := range object {
+		if err := flatten(strings.TrimPrefix(prefix+"."+key, "."), nested, settings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
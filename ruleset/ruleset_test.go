@@ -0,0 +1,126 @@
+package ruleset
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []Change
+	}{
+		{
+			name:   "the same settings should not differ",
+			before: `{"merchant": {"new_merchant_amount_us_cents": 1000}}`,
+			after:  `{ "merchant" : { "new_merchant_amount_us_cents" : 1000 } }`,
+			want:   []Change{},
+		},
+		{
+			name:   "changed, added and removed settings should be listed by path",
+			before: `{"merchant": {"new_merchant_amount_us_cents": 1000, "high_risk_categories": ["7995"]}, "card_sharing": {"high_risk_users": 2}}`,
+			after:  `{"merchant": {"new_merchant_amount_us_cents": 2000, "high_risk_categories": ["7995"]}, "anomaly": {"window": 10}}`,
+			want: []Change{
+				{Path: "anomaly.window", After: json.RawMessage(`10`)},
+				{Path: "card_sharing.high_risk_users", Before: json.RawMessage(`2`)},
+				{Path: "merchant.new_merchant_amount_us_cents", Before: json.RawMessage(`1000`), After: json.RawMessage(`2000`)},
+			},
+		},
+		{
+			name:   "arrays should be compared whole",
+			before: `{"merchant": {"high_risk_categories": ["7995", "6051"]}}`,
+			after:  `{"merchant": {"high_risk_categories": ["7995"]}}`,
+			want: []Change{
+				{Path: "merchant.high_risk_categories", Before: json.RawMessage(`["7995","6051"]`), After: json.RawMessage(`["7995"]`)},
+			},
+		},
+		{
+			name:   "empty objects should be settings",
+			before: `{}`,
+			after:  `{"type_thresholds": {}}`,
+			want:   []Change{{Path: "type_thresholds", After: json.RawMessage(`{}`)}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Diff(json.RawMessage(test.before), json.RawMessage(test.after))
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestStoreAppend(t *testing.T) {
+	store, err := Open("")
+	assert.NoError(t, err)
+
+	first, err := store.Append(Version{Author: "ana", Config: json.RawMessage(`{"anomaly": {"window": 10}}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), first.Number)
+	assert.False(t, first.CreatedAt.IsZero())
+	// the first version differs from an empty configuration
+	assert.Equal(t, []Change{{Path: "anomaly.window", After: json.RawMessage(`10`)}}, first.Diff)
+
+	second, err := store.Append(Version{Author: "bruno", Config: json.RawMessage(`{"anomaly": {"window": 20}}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), second.Number)
+	assert.Equal(t, []Change{{Path: "anomaly.window", Before: json.RawMessage(`10`), After: json.RawMessage(`20`)}}, second.Diff)
+
+	// versions are numbered per tenant
+	other, err := store.Append(Version{Tenant: "cards", Author: "ana", Config: json.RawMessage(`{}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), other.Number)
+
+	_, err = store.Append(Version{Config: json.RawMessage(`{}`)})
+	assert.ErrorIs(t, err, ErrNoAuthor)
+	_, err = store.Append(Version{Author: "ana", Config: json.RawMessage(`[]`)})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	_, err = store.Append(Version{Author: "ana", Config: json.RawMessage(`{`)})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+
+	assert.Equal(t, []Version{first, second}, store.List(""))
+	latest, isKnown := store.Latest("")
+	assert.True(t, isKnown)
+	assert.Equal(t, second, latest)
+	_, isKnown = store.Latest("loans")
+	assert.False(t, isKnown)
+
+	got, err := store.Get("", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, first, got)
+	_, err = store.Get("", 3)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.Get("cards", 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rule_versions.log")
+	store, err := Open(path)
+	assert.NoError(t, err)
+	_, err = store.Append(Version{Author: "ana", Config: json.RawMessage(`{"anomaly": {"window": 10}}`)})
+	assert.NoError(t, err)
+	second, err := store.Append(Version{Author: "ana", RestoredFrom: 1, Config: json.RawMessage(`{}`)})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	reopened, err := Open(path)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assert.Len(t, reopened.List(""), 2)
+	latest, _ := reopened.Latest("")
+	assert.Equal(t, second.Number, latest.Number)
+	assert.Equal(t, uint64(1), latest.RestoredFrom)
+	assert.True(t, second.CreatedAt.Equal(latest.CreatedAt))
+
+	// later versions continue the numbering
+	third, err := reopened.Append(Version{Author: "ana", Config: json.RawMessage(`{}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), third.Number)
+	assert.Empty(t, third.Diff)
+}
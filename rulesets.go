@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"transactionriskassessment/client"
+	"transactionriskassessment/engine"
+	"transactionriskassessment/ruleset"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// author of the versions read from the rules configuration files at startup
+	configFileAuthor = "config file"
+	// header carrying RULES_ADMIN_KEY, required to change the rules
+	rulesAdminKeyHeader = "X-Admin-Key"
+)
+
+var (
+	// errInvalidRules is returned when a configuration cannot be evaluated, e.g. a threshold is negative
+	errInvalidRules = errors.New("invalid rules configuration")
+	// errFileReference is returned when a configuration posted to the API names a file outside the tenant
+	// configuration directory
+	errFileReference = errors.New("configuration files can only be named relative to the tenant rules configuration directory, without ..")
+)
+
+// RULES_ADMIN_KEY, the credential for posting and activating versions. They are refused when empty
+var rulesAdminKey string
+
+// versions of every tenant rules, appended to RULE_VERSIONS_LOG when set
+var ruleVersions, _ = ruleset.Open("")
+
+// RULES_CONFIG, the rules file of the default tenant
+var rulesConfigPath string
+
+// returns the configuration file of the tenant as a version source, an empty object for the default configuration
+func (tenant *tenant) rulesSource() (json.RawMessage, error) {
+	if tenant.rulesConfig == "" {
+		return json.RawMessage(`{}`), nil
+	}
+	return os.ReadFile(tenant.rulesConfig)
+}
+
+// restoreRuleVersions records the rules every tenant was loaded with as a version. When the configuration file
+// did not change since the last startup, the latest version is activated instead, so the versions activated
+// through the API survive restarts
+func restoreRuleVersions() error {
+	all := []*tenant{defaultTenant()}
+	ids := make([]string, 0, len(tenants))
+	for id := range tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		all = append(all, tenants[id])
+	}
+
+	for _, tenant := range all {
+		source, err := tenant.rulesSource()
+		if err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.id, err)
+		}
+		latest, hasVersions := ruleVersions.Latest(tenant.id)
+		if hasVersions && sameConfig(lastFileVersion(tenant.id).Config, source) {
+			if latest.RuleSetVersion == tenant.engine.Version() {
+				continue
+			}
+			if _, err := activateRuleVersion(tenant, latest, false); err != nil {
+				return fmt.Errorf("tenant %s version %d: %w", tenant.id, latest.Number, err)
+			}
+			continue
+		}
+		if _, err := activateRuleVersion(tenant, ruleset.Version{Author: configFileAuthor, Config: source}, true); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.id, err)
+		}
+	}
+	return nil
+}
+
+// returns the latest version of the tenant read from its configuration file, an empty one when none was
+func lastFileVersion(tenantId string) ruleset.Version {
+	versions := ruleVersions.List(tenantId)
+	for index := len(versions) - 1; index >= 0; index-- {
+		if versions[index].Author == configFileAuthor {
+			return versions[index]
+		}
+	}
+	return ruleset.Version{}
+}
+
+// tells whether the two configurations are the same JSON, ignoring the spacing
+func sameConfig(stored, source json.RawMessage) bool {
+	var compacted bytes.Buffer
+	return json.Compact(&compacted, source) == nil && bytes.Equal(compacted.Bytes(), stored)
+}
+
+// activateRuleVersion evaluates the tenant transactions with the configuration of the version from now on,
+// keeping their history. When isNew, the version is appended first, and returned with its number set
+func activateRuleVersion(tenant *tenant, version ruleset.Version, isNew bool) (ruleset.Version, error) {
+	config, err := engine.ParseConfig(version.Config, filepath.Dir(tenant.rulesConfig))
+	if err != nil {
+		return version, fmt.Errorf("%w: %w", errInvalidRules, err)
+	}
+
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	// read again, the engine may have been replaced since the tenant was resolved
+	current := riskEngine
+	if named, isKnown := tenants[tenant.id]; isKnown {
+		current = named.engine
+	}
+	reconfigured := current.Reconfigure(config)
+
+	if isNew {
+		version.Tenant, version.RuleSetVersion = tenant.id, reconfigured.Version()
+		if version, err = ruleVersions.Append(version); err != nil {
+			return version, err
+		}
+	}
+	if named, isKnown := tenants[tenant.id]; isKnown {
+		named.engine = reconfigured
+	} else {
+		riskEngine = reconfigured
+	}
+	return version, nil
+}
+
+// requireRulesAdmin refuses requests without the rules admin key, all of them when none is set
+func requireRulesAdmin(context *gin.Context) {
+	if rulesAdminKey == "" {
+		context.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "changing the rules is disabled, RULES_ADMIN_KEY is not set"})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(context.GetHeader(rulesAdminKeyHeader)), []byte(rulesAdminKey)) != 1 {
+		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid " + rulesAdminKeyHeader})
+		return
+	}
+	context.Next()
+}
+
+// checkFileReferences refuses configurations naming the IP blocklist or model file by an absolute path, or by one
+// leaving the tenant configuration directory, where ParseConfig reads them from. Tenants without a configuration
+// file cannot name any
+func checkFileReferences(tenant *tenant, config json.RawMessage) error {
+	var references struct {
+		Device struct {
+			IPBlocklistFile string `json:"ip_blocklist_file"`
+		} `json:"device"`
+		Model struct {
+			File string `json:"file"`
+		} `json:"model"`
+	}
+	if json.Unmarshal(config, &references) != nil {
+		// not a configuration, refused when it is parsed
+		return nil
+	}
+	for _, path := range []string{references.Device.IPBlocklistFile, references.Model.File} {
+		if path != "" && (tenant.rulesConfig == "" || !filepath.IsLocal(path)) {
+			return errFileReference
+		}
+	}
+	return nil
+}
+
+// ruleVersionInput is the body of POST /rule_versions, and of the activation, which only reads the author
+type ruleVersionInput struct {
+	Author string          `json:"author"`
+	Config json.RawMessage `json:"config"`
+}
+
+// HTTP status of a rule set version error
+func ruleVersionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ruleset.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ruleset.ErrNoAuthor), errors.Is(err, ruleset.ErrInvalidConfig), errors.Is(err, errInvalidRules),
+		errors.Is(err, errFileReference):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// ListRuleVersions returns the versions of the tenant rules, the oldest first, and the number of the active one
+func ListRuleVersions(context *gin.Context) {
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	versions := ruleVersions.List(tenant.id)
+	if versions == nil {
+		versions = []ruleset.Version{}
+	}
+	var active uint64
+	if len(versions) > 0 {
+		active = versions[len(versions)-1].Number
+	}
+	context.IndentedJSON(http.StatusOK, gin.H{"active": active, "versions": versions})
+}
+
+// PostRuleVersion applies a new configuration to the tenant, recording it as its newest version
+func PostRuleVersion(context *gin.Context) {
+	var input ruleVersionInput
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := context.BindJSON(&input); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Author == "" {
+		context.JSON(http.StatusBadRequest, gin.H{"error": ruleset.ErrNoAuthor.Error()})
+		return
+	}
+
+	if err := checkFileReferences(tenant, input.Config); err != nil {
+		context.JSON(ruleVersionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	version, err := activateRuleVersion(tenant, ruleset.Version{Author: input.Author, Config: input.Config}, true)
+	if err != nil {
+		context.JSON(ruleVersionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	context.IndentedJSON(http.StatusCreated, version)
+}
+
+// ActivateRuleVersion rolls the tenant rules back, or forward, to an earlier version. The change is itself
+// recorded as the newest version, restored from the one activated
+func ActivateRuleVersion(context *gin.Context) {
+	var input ruleVersionInput
+	tenant, err := resolveTenant(context.GetHeader(tenantHeader), context.GetHeader(client.APIKeyHeader))
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	number, err := strconv.ParseUint(context.Param("number"), 10, 64)
+	if err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": "invalid version number"})
+		return
+	}
+	if err := context.BindJSON(&input); err != nil {
+		context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Author == "" {
+		context.JSON(http.StatusBadRequest, gin.H{"error": ruleset.ErrNoAuthor.Error()})
+		return
+	}
+
+	restored, err := ruleVersions.Get(tenant.id, number)
+	if err != nil {
+		context.JSON(ruleVersionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	version, err := activateRuleVersion(tenant, ruleset.Version{Author: input.Author, RestoredFrom: number, Config: restored.Config}, true)
+	if err != nil {
+		context.JSON(ruleVersionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	context.IndentedJSON(http.StatusCreated, version)
+}
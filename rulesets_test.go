@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"transactionriskassessment/engine"
+	"transactionriskassessment/ruleset"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// headers of the requests changing the rules, with the admin key enableTestRuleVersions sets
+var testRulesAdmin = map[string]string{rulesAdminKeyHeader: "admin-key"}
+
+// records the rules of every tenant as their first version, in a store kept for the test only, and sets the
+// rules admin key. The default engine is replaced by one with history
+func enableTestRuleVersions(t *testing.T) {
+	useTestFeedback(t)
+	previousVersions, previousPath, previousAdminKey := ruleVersions, rulesConfigPath, rulesAdminKey
+	ruleVersions, _ = ruleset.Open("")
+	rulesAdminKey = testRulesAdmin[rulesAdminKeyHeader]
+	t.Cleanup(func() {
+		ruleVersions, rulesConfigPath, rulesAdminKey = previousVersions, previousPath, previousAdminKey
+	})
+	if err := restoreRuleVersions(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// decodes the version of a rule versions response
+func decodeRuleVersion(t *testing.T, body []byte) ruleset.Version {
+	var version ruleset.Version
+	if err := json.Unmarshal(body, &version); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+	return version
+}
+
+func TestRuleVersions_Workflow(t *testing.T) {
+	enableTestRuleVersions(t)
+	defaultVersion := riskEngine.Version()
+	history := riskEngine.History()
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 2000, "card_id": 1, "merchant_id": 9}]}`
+
+	status, body := serveDocumentedRequest(t, "GET", "/rule_versions", "/rule_versions", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	var list struct {
+		Active   uint64
+		Versions []ruleset.Version
+	}
+	assert.NoError(t, json.Unmarshal(body, &list))
+	assert.Equal(t, uint64(1), list.Active)
+	assert.Len(t, list.Versions, 1)
+	assert.Equal(t, configFileAuthor, list.Versions[0].Author)
+	assert.Equal(t, defaultVersion, list.Versions[0].RuleSetVersion)
+
+	status, body = serveDocumentedRequest(t, "POST", "/rule_versions", "/rule_versions", `{"author": "ana", "config": {"merchant": {"new_merchant_amount_us_cents": 1000}}}`, testRulesAdmin)
+	assert.Equal(t, http.StatusCreated, status)
+	changed := decodeRuleVersion(t, body)
+	assert.Equal(t, uint64(2), changed.Number)
+	assert.Equal(t, "ana", changed.Author)
+	assert.Equal(t, []ruleset.Change{{Path: "merchant.new_merchant_amount_us_cents", After: json.RawMessage(`1000`)}}, changed.Diff)
+	assert.NotEqual(t, defaultVersion, changed.RuleSetVersion)
+
+	// the new merchant is now flagged, by the new version
+	recorder := serveTestRequest("POST", "/check_transactions", payload, nil)
+	assert.JSONEq(t, `{"risk_ratings": ["medium"], "actions": ["approve_with_challenge"], "rule_set_version": "`+changed.RuleSetVersion+`"}`, recorder.Body.String())
+
+	status, body = serveDocumentedRequest(t, "POST", "/rule_versions/1/activate", "/rule_versions/{number}/activate", `{"author": "bruno"}`, testRulesAdmin)
+	assert.Equal(t, http.StatusCreated, status)
+	restored := decodeRuleVersion(t, body)
+	assert.Equal(t, uint64(3), restored.Number)
+	assert.Equal(t, uint64(1), restored.RestoredFrom)
+	assert.Equal(t, defaultVersion, restored.RuleSetVersion)
+	assert.Equal(t, []ruleset.Change{{Path: "merchant.new_merchant_amount_us_cents", Before: json.RawMessage(`1000`)}}, restored.Diff)
+
+	recorder = serveTestRequest("POST", "/check_transactions", `{"transactions": [{"id": 2, "user_id": 2, "amount_us_cents": 2000, "card_id": 2, "merchant_id": 9}]}`, nil)
+	assert.JSONEq(t, `{"risk_ratings": ["low"], "actions": ["approve"], "rule_set_version": "`+defaultVersion+`"}`, recorder.Body.String())
+	// the previous assessments are still remembered
+	assert.Same(t, history, riskEngine.History())
+	assert.Len(t, history.UserAmounts(1), 1)
+}
+
+func TestRuleVersions_Errors(t *testing.T) {
+	enableTestRuleVersions(t)
+	activeVersion := riskEngine.Version()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		documentedPath string
+		payload        string
+		status         int
+	}{
+		{name: "no author", method: "POST", path: "/rule_versions", documentedPath: "/rule_versions", payload: `{"config": {}}`, status: http.StatusBadRequest},
+		{name: "no config", method: "POST", path: "/rule_versions", documentedPath: "/rule_versions", payload: `{"author": "ana"}`, status: http.StatusBadRequest},
+		{name: "config other than an object", method: "POST", path: "/rule_versions", documentedPath: "/rule_versions", payload: `{"author": "ana", "config": null}`, status: http.StatusBadRequest},
+		{name: "invalid rules", method: "POST", path: "/rule_versions", documentedPath: "/rule_versions", payload: `{"author": "ana", "config": {"card_sharing": {"medium_risk_users": -1}}}`, status: http.StatusBadRequest},
+		{name: "unknown version", method: "POST", path: "/rule_versions/9/activate", documentedPath: "/rule_versions/{number}/activate", payload: `{"author": "ana"}`, status: http.StatusNotFound},
+		{name: "invalid version number", method: "POST", path: "/rule_versions/first/activate", documentedPath: "/rule_versions/{number}/activate", payload: `{"author": "ana"}`, status: http.StatusBadRequest},
+		{name: "activation without author", method: "POST", path: "/rule_versions/1/activate", documentedPath: "/rule_versions/{number}/activate", payload: `{}`, status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, _ := serveDocumentedRequest(t, test.method, test.path, test.documentedPath, test.payload, testRulesAdmin)
+			assert.Equal(t, test.status, status)
+		})
+	}
+
+	// nothing was applied nor recorded
+	assert.Equal(t, activeVersion, riskEngine.Version())
+	assert.Len(t, ruleVersions.List(defaultTenantId), 1)
+}
+
+func TestRuleVersions_Tenants(t *testing.T) {
+	enableTestTenants(t)
+	enableTestRuleVersions(t)
+	cards := map[string]string{tenantHeader: "cards"}
+	cardsAdmin := map[string]string{tenantHeader: "cards", rulesAdminKeyHeader: testRulesAdmin[rulesAdminKeyHeader]}
+	defaultVersion := riskEngine.Version()
+
+	// the cards tenant starts with the rules of its file
+	recorder := serveTestRequest("GET", "/rule_versions", "", cards)
+	var list struct{ Versions []ruleset.Version }
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &list))
+	assert.Len(t, list.Versions, 1)
+	assert.JSONEq(t, `{"merchant": {"new_merchant_amount_us_cents": 1000}}`, string(list.Versions[0].Config))
+
+	recorder = serveTestRequest("POST", "/rule_versions", `{"author": "ana", "config": {"merchant": {"new_merchant_amount_us_cents": 5000}}}`, cardsAdmin)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	version := decodeRuleVersion(t, recorder.Body.Bytes())
+	assert.Equal(t, uint64(2), version.Number)
+	assert.Equal(t, "cards", version.Tenant)
+	assert.Equal(t, version.RuleSetVersion, tenants["cards"].engine.Version())
+
+	// the other tenants keep their rules
+	assert.Equal(t, defaultVersion, riskEngine.Version())
+	assert.Len(t, ruleVersions.List(defaultTenantId), 1)
+	assert.Len(t, ruleVersions.List("loans"), 1)
+}
+
+func TestRestoreRuleVersions(t *testing.T) {
+	enableTestRuleVersions(t)
+	directory := writeTestFiles(t, map[string]string{"rules.json": `{"merchant": {"new_merchant_amount_us_cents": 1000}}`})
+	rulesConfigPath = filepath.Join(directory, "rules.json")
+	logPath := filepath.Join(directory, "rule_versions.log")
+
+	// restarts the server, with the rules of its file and the versions of the log
+	restart := func() {
+		t.Helper()
+		if err := ruleVersions.Close(); err != nil {
+			t.Fatal(err)
+		}
+		useTestFeedback(t)
+		config, err := engine.LoadConfig(rulesConfigPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		riskEngine = riskEngine.Reconfigure(config)
+		if ruleVersions, err = ruleset.Open(logPath); err != nil {
+			t.Fatal(err)
+		}
+		if err := restoreRuleVersions(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { ruleVersions.Close() })
+
+	restart()
+	fileVersion := riskEngine.Version()
+	assert.Len(t, ruleVersions.List(defaultTenantId), 1)
+
+	recorder := serveTestRequest("POST", "/rule_versions", `{"author": "ana", "config": {}}`, testRulesAdmin)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+	posted := decodeRuleVersion(t, recorder.Body.Bytes())
+
+	// the file did not change, the version activated through the API is restored
+	restart()
+	assert.Equal(t, posted.RuleSetVersion, riskEngine.Version())
+	assert.Len(t, ruleVersions.List(defaultTenantId), 2)
+
+	// the file changed, it is recorded and applied
+	if err := os.WriteFile(rulesConfigPath, []byte(`{"merchant": {"new_merchant_amount_us_cents": 2000}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	restart()
+	versions := ruleVersions.List(defaultTenantId)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, configFileAuthor, versions[2].Author)
+	assert.Equal(t, riskEngine.Version(), versions[2].RuleSetVersion)
+	assert.NotEqual(t, fileVersion, riskEngine.Version())
+}
+
+func TestRuleVersions_AdminKey(t *testing.T) {
+	enableTestRuleVersions(t)
+	activeVersion := riskEngine.Version()
+
+	tests := []struct {
+		name     string
+		adminKey string
+		headers  map[string]string
+		status   int
+	}{
+		{name: "no admin key header", adminKey: "admin-key", status: http.StatusUnauthorized},
+		{name: "wrong admin key", adminKey: "admin-key", headers: map[string]string{rulesAdminKeyHeader: "guess"}, status: http.StatusUnauthorized},
+		{name: "tenant API key", adminKey: "admin-key", headers: map[string]string{"X-API-Key": "admin-key"}, status: http.StatusUnauthorized},
+		{name: "no admin key set", adminKey: "", headers: map[string]string{rulesAdminKeyHeader: ""}, status: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rulesAdminKey = test.adminKey
+			status, _ := serveDocumentedRequest(t, "POST", "/rule_versions", "/rule_versions", `{"author": "ana", "config": {}}`, test.headers)
+			assert.Equal(t, test.status, status)
+			status, _ = serveDocumentedRequest(t, "POST", "/rule_versions/1/activate", "/rule_versions/{number}/activate", `{"author": "ana"}`, test.headers)
+			assert.Equal(t, test.status, status)
+		})
+	}
+
+	// listing needs no admin key, and nothing was changed
+	status, _ := serveDocumentedRequest(t, "GET", "/rule_versions", "/rule_versions", "", nil)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, activeVersion, riskEngine.Version())
+	assert.Len(t, ruleVersions.List(defaultTenantId), 1)
+}
+
+func TestRuleVersions_FileReferences(t *testing.T) {
+	enableTestTenants(t)
+	enableTestRuleVersions(t)
+	cardsDirectory := filepath.Dir(tenants["cards"].rulesConfig)
+	if err := os.WriteFile(filepath.Join(cardsDirectory, "blocklist.txt"), []byte("203.0.113.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cardsDirectory, "secret.txt"), []byte("password\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cardsAdmin := map[string]string{tenantHeader: "cards", rulesAdminKeyHeader: testRulesAdmin[rulesAdminKeyHeader]}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		config  string
+		status  int
+	}{
+		{name: "absolute path", headers: cardsAdmin, config: `{"device": {"ip_blocklist_file": "/etc/passwd"}}`, status: http.StatusBadRequest},
+		{name: "path leaving the directory", headers: cardsAdmin, config: `{"model": {"file": "../model.json"}}`, status: http.StatusBadRequest},
+		{name: "tenant without configuration file", headers: testRulesAdmin, config: `{"device": {"ip_blocklist_file": "blocklist.txt"}}`, status: http.StatusBadRequest},
+		{name: "file of the tenant directory", headers: cardsAdmin, config: `{"device": {"ip_blocklist_file": "blocklist.txt"}}`, status: http.StatusCreated},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, body := serveDocumentedRequest(t, "POST", "/rule_versions", "/rule_versions", `{"author": "ana", "config": `+test.config+`}`, test.headers)
+			assert.Equal(t, test.status, status, string(body))
+		})
+	}
+
+	// a file that is not a blocklist is refused without quoting it
+	status, body := serveDocumentedRequest(t, "POST", "/rule_versions", "/rule_versions", `{"author": "ana", "config": {"device": {"ip_blocklist_file": "secret.txt"}}}`, cardsAdmin)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.NotContains(t, string(body), "password")
+}
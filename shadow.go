@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+	"transactionriskassessment/engine"
+)
+
+// shadow engine counters, served with the other expvar variables at /debug/vars
+var shadowMetrics = expvar.NewMap("shadow")
+
+// line of the divergences log, one JSON object per divergent transaction
+type divergenceLogEntry struct {
+	Time time.Time `json:"time"`
+	engine.Divergence
+}
+
+// loadShadow builds the challenger engine from the JSON file at path, logging its divergences to logPath. It is only
+// attached to the default tenant engine, so its single history never mixes the ids of several tenants
+func loadShadow(path, logPath string) (engine.Shadow, error) {
+	config, err := engine.LoadConfig(path)
+	if err != nil {
+		return engine.Shadow{}, err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return engine.Shadow{}, err
+	}
+
+	return engine.Shadow{
+		// its own history, so it does not see the primary engine's state. Analysts feedback is shared
+		Engine: engine.New(engine.WithConfig(config), engine.WithHistory(engine.NewHistory()), engine.WithFraudLabels(feedbackStore)),
+		Report: newShadowReporter(logFile),
+	}, nil
+}
+
+// newShadowReporter returns a Report function updating shadowMetrics and writing each divergence to divergenceLog
+func newShadowReporter(divergenceLog io.Writer) func(engine.ShadowReport) {
+	// requests are assessed concurrently, lines must not interleave
+	var mutex sync.Mutex
+
+	return func(report engine.ShadowReport) {
+		shadowMetrics.Add("batches", 1)
+		if errors.Is(report.Err, engine.ErrShadowQueueFull) || errors.Is(report.Err, engine.ErrShadowStopped) {
+			shadowMetrics.Add("dropped", 1)
+			return
+		}
+		if report.Err != nil {
+			shadowMetrics.Add("errors", 1)
+			return
+		}
+		shadowMetrics.Add("transactions", int64(report.Transactions))
+		shadowMetrics.Add("divergences", int64(len(report.Divergences)))
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		now := time.Now().UTC()
+		for _, divergence := range report.Divergences {
+			// e.g. divergences_low_to_high
+			shadowMetrics.Add("divergences_"+divergence.PrimaryRisk+"_to_"+divergence.ShadowRisk, 1)
+
+			line, err := json.Marshal(divergenceLogEntry{Time: now, Divergence: divergence})
+			if err != nil {
+				log.Printf("encoding shadow divergence: %v", err)
+				continue
+			}
+			if _, err := divergenceLog.Write(append(line, '\n')); err != nil {
+				log.Printf("writing shadow divergence: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShadowReporter(t *testing.T) {
+	var divergenceLog bytes.Buffer
+	report := newShadowReporter(&divergenceLog)
+	// the counters are global, other tests may have moved them
+	var before int64
+	if value := shadowMetrics.Get("divergences_low_to_high"); value != nil {
+		before = mustParseInt(t, value.String())
+	}
+
+	report(engine.ShadowReport{
+		Transactions: 2,
+		Divergences: []engine.Divergence{{
+			Transaction: domain.Transaction{TransactionId: 7, UserId: 3, Amount: domain.USD(2000)},
+			PrimaryRisk: "low",
+			ShadowRisk:  "high",
+		}},
+	})
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(divergenceLog.Bytes(), &entry))
+	assert.Equal(t, "low", entry["primary_risk"])
+	assert.Equal(t, "high", entry["shadow_risk"])
+	assert.Equal(t, float64(7), entry["transaction"].(map[string]any)["id"])
+	assert.Contains(t, entry, "time")
+
+	assert.Equal(t, before+1, mustParseInt(t, shadowMetrics.Get("divergences_low_to_high").String()))
+
+	var droppedBefore int64
+	if value := shadowMetrics.Get("dropped"); value != nil {
+		droppedBefore = mustParseInt(t, value.String())
+	}
+	report(engine.ShadowReport{Transactions: 1, Err: engine.ErrShadowQueueFull})
+	report(engine.ShadowReport{Transactions: 1, Err: engine.ErrShadowStopped})
+	assert.Equal(t, droppedBefore+2, mustParseInt(t, shadowMetrics.Get("dropped").String()))
+}
+
+func TestLoadShadow(t *testing.T) {
+	directory := t.TempDir()
+	configPath := filepath.Join(directory, "shadow.json")
+	logPath := filepath.Join(directory, "divergences.log")
+	// the shadow flags every new merchant above 1000 cents
+	if err := os.WriteFile(configPath, []byte(`{"merchant": {"new_merchant_amount_us_cents": 1000}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	shadow, err := loadShadow(configPath, logPath)
+	assert.NoError(t, err)
+
+	riskEngine := engine.New(engine.WithShadow(shadow))
+	got, err := riskEngine.Assess(context.Background(), []domain.Transaction{{TransactionId: 1, UserId: 1, Amount: domain.USD(2000), MerchantId: 9}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"low"}, got.RiskRates)
+
+	// the shadow engine runs after the primary results are returned
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(logPath)
+		return err == nil && strings.Contains(string(content), `"shadow_risk":"medium"`)
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = loadShadow(filepath.Join(directory, "missing.json"), logPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// parses an expvar integer
+func mustParseInt(t *testing.T, value string) int64 {
+	var number int64
+	if err := json.Unmarshal([]byte(value), &number); err != nil {
+		t.Fatal(err)
+	}
+	return number
+}
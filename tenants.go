@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"transactionriskassessment/domain"
+	"transactionriskassessment/engine"
+	"transactionriskassessment/feedback"
+)
+
+// header selecting the tenant of a request, tenants can also be identified by their API key
+const tenantHeader = "X-Tenant-Id"
+
+// tenant served when the request names none, with the engine and feedback of RULES_CONFIG and FEEDBACK_LOG
+const defaultTenantId = "default"
+
+var (
+	// errUnknownTenant is returned for tenant ids not in TENANTS_CONFIG
+	errUnknownTenant = errors.New("unknown tenant")
+	// errTenantMismatch is returned when the API key belongs to another tenant than the one named
+	errTenantMismatch = errors.New("API key belongs to another tenant")
+)
+
+// tenant is a business unit assessed with its own rules. Each tenant has its own history and feedback,
+// so the same user id in two tenants is two different users
+type tenant struct {
+	id       string
+	engine   *engine.Engine
+	feedback *feedback.Store
+	// file the rules were loaded from at startup, empty for the default configuration
+	rulesConfig string
+}
+
+// tenantSettings is one tenant of the TENANTS_CONFIG file
+type tenantSettings struct {
+	// rules configuration file, the default configuration when empty
+	RulesConfig string `json:"rules_config"`
+	// keys identifying the tenant without the X-Tenant-Id header
+	APIKeys []string `json:"api_keys"`
+	// feedback file, kept in memory when empty
+	FeedbackLog string `json:"feedback_log"`
+}
+
+// tenants of TENANTS_CONFIG by id and by API key, nil when it is unset
+var (
+	tenants         map[string]*tenant
+	tenantsByAPIKey map[string]*tenant
+)
+
+// guards the engines of the tenants, replaced when another rule set version is activated
+var rulesMutex sync.RWMutex
+
+// per tenant counters, served under "tenants" at /debug/vars
+var (
+	tenantMetrics = expvar.NewMap("tenants")
+	// serializes the creation of a tenant counters
+	tenantMetricsMutex sync.Mutex
+)
+
+// loadTenants builds the tenants of the JSON file at path, an object of tenantSettings by tenant id
+func loadTenants(path string) (map[string]*tenant, map[string]*tenant, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var settings map[string]tenantSettings
+	if err := json.Unmarshal(content, &settings); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// sorted, so errors do not depend on the map order
+	ids := make([]string, 0, len(settings))
+	for id := range settings {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	byId, byAPIKey := make(map[string]*tenant, len(settings)), make(map[string]*tenant)
+	for _, id := range ids {
+		if id == "" || id == defaultTenantId {
+			return nil, nil, fmt.Errorf("tenant id %q is reserved", id)
+		}
+		tenant, err := newTenant(id, settings[id])
+		if err != nil {
+			return nil, nil, fmt.Errorf("tenant %s: %w", id, err)
+		}
+		for _, apiKey := range settings[id].APIKeys {
+			if other, isTaken := byAPIKey[apiKey]; isTaken {
+				return nil, nil, fmt.Errorf("tenant %s: API key already used by tenant %s", id, other.id)
+			}
+			byAPIKey[apiKey] = tenant
+		}
+		byId[id] = tenant
+	}
+	return byId, byAPIKey, nil
+}
+
+// builds the engine of the tenant, remembering previous requests as the default one does
+func newTenant(id string, settings tenantSettings) (*tenant, error) {
+	config := engine.DefaultConfig()
+	if settings.RulesConfig != "" {
+		var err error
+		if config, err = engine.LoadConfig(settings.RulesConfig); err != nil {
+			return nil, err
+		}
+	}
+	store, err := feedback.Open(settings.FeedbackLog)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tenant{
+		id:          id,
+		engine:      engine.New(engine.WithConfig(config), engine.WithHistory(engine.NewHistory()), engine.WithFraudLabels(store)),
+		feedback:    store,
+		rulesConfig: settings.RulesConfig,
+	}, nil
+}
+
+// returns the default tenant, read on every call since main, the tests and activated versions replace the shared engine
+func defaultTenant() *tenant {
+	rulesMutex.RLock()
+	defer rulesMutex.RUnlock()
+	return &tenant{id: defaultTenantId, engine: riskEngine, feedback: feedbackStore, rulesConfig: rulesConfigPath}
+}
+
+// returns a copy of the tenant, so a request keeps the same engine while another version is activated
+func (tenant *tenant) snapshot() *tenant {
+	rulesMutex.RLock()
+	defer rulesMutex.RUnlock()
+	snapshot := *tenant
+	return &snapshot
+}
+
+// resolveTenant returns the tenant named by tenantId or, when empty, the one owning the API key,
+// falling back to the default tenant
+func resolveTenant(tenantId, apiKey string) (*tenant, error) {
+	keyTenant := tenantsByAPIKey[apiKey]
+	switch {
+	case tenantId == "" && keyTenant != nil:
+		return keyTenant.snapshot(), nil
+	case tenantId == "" || tenantId == defaultTenantId:
+		if keyTenant != nil {
+			return nil, errTenantMismatch
+		}
+		return defaultTenant(), nil
+	}
+
+	named, isKnown := tenants[tenantId]
+	if !isKnown {
+		return nil, fmt.Errorf("%w %q", errUnknownTenant, tenantId)
+	}
+	if keyTenant != nil && keyTenant != named {
+		return nil, errTenantMismatch
+	}
+	return named.snapshot(), nil
+}
+
+// tells whether the tenant owns what was recorded for recordTenant. Records written before tenants existed have
+// none, they belong to the default tenant
+func (tenant *tenant) owns(recordTenant string) bool {
+	if recordTenant == "" {
+		recordTenant = defaultTenantId
+	}
+	return recordTenant == tenant.id
+}
+
+// counts the request and its ratings under the tenant, e.g. tenants.cards.risk_high
+func (tenant *tenant) recordMetrics(results domain.RiskRateResults, err error) {
+	tenantMetricsMutex.Lock()
+	metrics, _ := tenantMetrics.Get(tenant.id).(*expvar.Map)
+	if metrics == nil {
+		metrics = new(expvar.Map).Init()
+		tenantMetrics.Set(tenant.id, metrics)
+	}
+	tenantMetricsMutex.Unlock()
+
+	metrics.Add("requests", 1)
+	if err != nil {
+		metrics.Add("errors", 1)
+		return
+	}
+	metrics.Add("transactions", int64(len(results.RiskRates)))
+	for _, risk := range results.RiskRates {
+		metrics.Add("risk_"+risk, 1)
+	}
+}
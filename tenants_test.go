@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"transactionriskassessment/riskpb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// writes the files in a temporary directory, returning the directory
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+// enables two tenants for the duration of the test: cards, which flags every new merchant above 1000 cents and
+// is owned by the cards-key API key, and loans, with the default rules
+func enableTestTenants(t *testing.T) {
+	directory := writeTestFiles(t, map[string]string{"cards.json": `{"merchant": {"new_merchant_amount_us_cents": 1000}}`})
+	path := filepath.Join(directory, "tenants.json")
+	settings := `{"cards": {"rules_config": "` + filepath.Join(directory, "cards.json") + `", "api_keys": ["cards-key"]}, "loans": {}}`
+	if err := os.WriteFile(path, []byte(settings), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var err error
+	if tenants, tenantsByAPIKey, err = loadTenants(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tenants, tenantsByAPIKey = nil, nil })
+}
+
+func TestLoadTenants(t *testing.T) {
+	directory := writeTestFiles(t, map[string]string{
+		"reserved.json":  `{"default": {}}`,
+		"duplicate.json": `{"cards": {"api_keys": ["key"]}, "loans": {"api_keys": ["key"]}}`,
+		"missing.json":   `{"cards": {"rules_config": "missing.json"}}`,
+		"invalid.json":   `{"cards": `,
+	})
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "reserved id", file: "reserved.json", wantErr: `tenant id "default" is reserved`},
+		{name: "API key of two tenants", file: "duplicate.json", wantErr: "tenant loans: API key already used by tenant cards"},
+		{name: "missing rules configuration", file: "missing.json", wantErr: "tenant cards: open missing.json"},
+		{name: "invalid JSON", file: "invalid.json", wantErr: "invalid.json: unexpected end of JSON input"},
+		{name: "missing file", file: "tenants.json", wantErr: "no such file or directory"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := loadTenants(filepath.Join(directory, test.file))
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	enableTestTenants(t)
+
+	tests := []struct {
+		name     string
+		tenantId string
+		apiKey   string
+		want     string
+		wantErr  error
+	}{
+		{name: "nothing named", want: defaultTenantId},
+		{name: "default named", tenantId: "default", want: defaultTenantId},
+		{name: "key of no tenant", apiKey: "other-key", want: defaultTenantId},
+		{name: "tenant named", tenantId: "loans", want: "loans"},
+		{name: "tenant of the key", apiKey: "cards-key", want: "cards"},
+		{name: "tenant named with its key", tenantId: "cards", apiKey: "cards-key", want: "cards"},
+		{name: "unknown tenant", tenantId: "mortgages", wantErr: errUnknownTenant},
+		{name: "key of another tenant", tenantId: "loans", apiKey: "cards-key", wantErr: errTenantMismatch},
+		{name: "default named with the key of a tenant", tenantId: "default", apiKey: "cards-key", wantErr: errTenantMismatch},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := resolveTenant(test.tenantId, test.apiKey)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got.id)
+		})
+	}
+}
+
+func TestAssessTransactions_Tenants(t *testing.T) {
+	useTestFeedback(t)
+	enableTestTenants(t)
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 2000, "card_id": 1, "merchant_id": 9}]}`
+	// the counters are global, other tests may have moved them
+	requestsBefore := tenantCounter(t, "cards", "requests")
+	defaultVersion, cardsVersion := riskEngine.Version(), tenants["cards"].engine.Version()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+		want    string
+	}{
+		{name: "default tenant", status: http.StatusOK, want: `{"risk_ratings": ["low"], "actions": ["approve"], "rule_set_version": "` + defaultVersion + `"}`},
+		{name: "tenant header", headers: map[string]string{tenantHeader: "cards"}, status: http.StatusOK, want: `{"risk_ratings": ["medium"], "actions": ["approve_with_challenge"], "rule_set_version": "` + cardsVersion + `"}`},
+		{name: "tenant API key", headers: map[string]string{"X-API-Key": "cards-key"}, status: http.StatusOK, want: `{"risk_ratings": ["medium"], "actions": ["approve_with_challenge"], "rule_set_version": "` + cardsVersion + `"}`},
+		{name: "unknown tenant", headers: map[string]string{tenantHeader: "mortgages"}, status: http.StatusBadRequest, want: `{"error": "unknown tenant \"mortgages\""}`},
+		{name: "key of another tenant", headers: map[string]string{tenantHeader: "loans", "X-API-Key": "cards-key"}, status: http.StatusBadRequest, want: `{"error": "API key belongs to another tenant"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveTestRequest("POST", "/check_transactions", payload, test.headers)
+			assert.Equal(t, test.status, recorder.Code)
+			assert.JSONEq(t, test.want, recorder.Body.String())
+		})
+	}
+
+	assert.Equal(t, requestsBefore+2, tenantCounter(t, "cards", "requests"))
+	assert.Positive(t, tenantCounter(t, "cards", "risk_medium"))
+}
+
+func TestAssessTransactions_TenantsIsolateState(t *testing.T) {
+	useTestFeedback(t)
+	enableTestTenants(t)
+	loans := map[string]string{tenantHeader: "loans"}
+	payload := `{"transactions": [{"id": 1, "user_id": 1, "amount_us_cents": 100, "card_id": 1}]}`
+
+	serveTestRequest("POST", "/check_transactions", payload, loans)
+	// the parties of transaction 1 are only known to the loans history
+	recorder := serveTestRequest("POST", "/feedback", `{"transaction_id": 1, "label": "fraud"}`, nil)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	recorder = serveTestRequest("POST", "/feedback", `{"transaction_id": 1, "label": "fraud"}`, loans)
+	assert.Equal(t, http.StatusCreated, recorder.Code)
+
+	recorder = serveTestRequest("POST", "/check_transactions", payload, loans)
+	assert.Equal(t, []string{"high"}, decodeRatings(t, recorder))
+	// the same card and user in other tenants are other people's
+	recorder = serveTestRequest("POST", "/check_transactions", payload, nil)
+	assert.Equal(t, []string{"low"}, decodeRatings(t, recorder))
+	recorder = serveTestRequest("POST", "/check_transactions", payload, map[string]string{tenantHeader: "cards"})
+	assert.Equal(t, []string{"low"}, decodeRatings(t, recorder))
+}
+
+func TestGRPCCheckTransactions_Tenants(t *testing.T) {
+	enableTestTenants(t)
+	client := newTestGRPCClient(t)
+	input := &riskpb.TransactionsInput{Transactions: []*riskpb.Transaction{{Id: 1, UserId: 1, AmountUsCents: 2000, CardId: 1, MerchantId: 9}}}
+
+	got, err := client.CheckTransactions(metadata.AppendToOutgoingContext(context.Background(), tenantMetadata, "cards"), input)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"medium"}, got.GetRiskRatings())
+	assert.Equal(t, tenants["cards"].engine.Version(), got.GetRuleSetVersion())
+
+	_, err = client.CheckTransactions(metadata.AppendToOutgoingContext(context.Background(), tenantMetadata, "mortgages"), input)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// returns a counter of the tenant metrics, 0 when it was never set
+func tenantCounter(t *testing.T, tenantId, name string) int64 {
+	metrics, isMap := tenantMetrics.Get(tenantId).(*expvar.Map)
+	if !isMap {
+		return 0
+	}
+	value := metrics.Get(name)
+	if value == nil {
+		return 0
+	}
+	return mustParseInt(t, value.String())
+}